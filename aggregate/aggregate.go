@@ -0,0 +1,1017 @@
+// Package aggregate combines a list of CIDR address blocks into the smallest
+// possible set of CIDR prefixes and writes them in one of many formats. It
+// is the engine of the aggregate-cidr command; programs can register their
+// own input and output formats with it and call Run directly.
+package aggregate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// maxSplitDepth limits how many prefix bits Subnets may add, so a careless
+// minimum length cannot expand one network into billions of entries.
+const maxSplitDepth = 20
+
+// CIDR represents a network with helper methods
+type CIDR struct {
+	net  *net.IPNet
+	ip   net.IP
+	ones int
+	bits int
+
+	// except lists excluded sub-networks for formats that express
+	// exclusions natively, such as Kubernetes ipBlocks
+	except []*CIDR
+
+	// annotations holds the trailing comments of the input lines this
+	// network was built from, in input order and without duplicates, up
+	// to maxAnnotations; omittedAnnotations counts the ones beyond that
+	annotations        []string
+	omittedAnnotations int
+}
+
+func parseCIDR(s string) (*CIDR, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") || strings.HasPrefix(s, ";") {
+		return nil, nil // skip empty lines and comments
+	}
+
+	// Extract just the IP/CIDR part (handle "IP/CIDR ; comment" format)
+	if idx := strings.IndexAny(s, " \t;#"); idx != -1 {
+		s = s[:idx]
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	// Add /32 or /128 if no prefix specified
+	if !strings.Contains(s, "/") {
+		if strings.Contains(s, ":") {
+			s += "/128"
+		} else {
+			s += "/32"
+		}
+	}
+
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %v", s, err)
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	return &CIDR{
+		net:  ipnet,
+		ip:   ipnet.IP,
+		ones: ones,
+		bits: bits,
+	}, nil
+}
+
+// ParseCIDR parses a network in CIDR notation or a single address, such as
+// 192.0.2.0/24 or 2001:db8::1, for input formats registered by other
+// packages. Host bits are cleared.
+func ParseCIDR(s string) (*CIDR, error) {
+	c, err := parseCIDR(s)
+	if err == nil && c == nil {
+		err = fmt.Errorf("invalid CIDR %q: no network", s)
+	}
+	return c, err
+}
+
+// NewCIDR returns the network of the given prefix length containing ip.
+// IPv4-mapped IPv6 addresses are treated as IPv4.
+func NewCIDR(ip net.IP, ones int) (*CIDR, error) {
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	} else if len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("invalid IP address %v", ip)
+	}
+	if ones < 0 || ones > bits {
+		return nil, fmt.Errorf("invalid prefix length %d for %v", ones, ip)
+	}
+	return newCIDR(ip, ones, bits), nil
+}
+
+// Addr returns the network address, e.g. 192.0.2.0.
+func (c *CIDR) Addr() net.IP {
+	return append(net.IP{}, c.ip...)
+}
+
+// Prefix returns the prefix length, e.g. 24.
+func (c *CIDR) Prefix() int {
+	return c.ones
+}
+
+// Bits returns the address length: 32 for IPv4 and 128 for IPv6.
+func (c *CIDR) Bits() int {
+	return c.bits
+}
+
+// Contains returns true if c fully contains other
+func (c *CIDR) Contains(other *CIDR) bool {
+	if c.bits != other.bits { // different IP versions
+		return false
+	}
+	if c.ones > other.ones { // c is smaller, can't contain other
+		return false
+	}
+	return c.net.Contains(other.ip)
+}
+
+// CanAggregate returns true if two CIDRs can be combined into one larger CIDR
+func (c *CIDR) CanAggregate(other *CIDR) bool {
+	if c.bits != other.bits || c.ones != other.ones {
+		return false
+	}
+	if c.ones == 0 {
+		return false // already at max size
+	}
+
+	// Two networks can aggregate if they differ only in the last bit of network portion
+	// Create parent mask (one bit less)
+	parentOnes := c.ones - 1
+	parentMask := net.CIDRMask(parentOnes, c.bits)
+
+	// Both networks must have the same parent
+	cParent := c.ip.Mask(parentMask)
+	otherParent := other.ip.Mask(parentMask)
+
+	return cParent.Equal(otherParent)
+}
+
+// Aggregate combines two CIDRs into their parent.
+// Both CIDRs mask to the same parent (verified by CanAggregate), so other
+// only contributes its annotations.
+func (c *CIDR) Aggregate(other *CIDR) *CIDR {
+	parent := c.Supernet(c.ones - 1)
+	parent.annotations, parent.omittedAnnotations = mergeAnnotations(c, other)
+	return parent
+}
+
+// Supernet returns the network of the given prefix length that covers c.
+// If ones is not shorter than c's own prefix length, c is returned unchanged.
+func (c *CIDR) Supernet(ones int) *CIDR {
+	if ones >= c.ones {
+		return c
+	}
+	supernet := newCIDR(c.ip, ones, c.bits)
+	supernet.annotations, supernet.omittedAnnotations = c.annotations, c.omittedAnnotations
+	return supernet
+}
+
+// Subnets splits c into its constituent networks of the given prefix length,
+// the inverse of Aggregate. If ones is not longer than c's own prefix length,
+// c is returned on its own.
+func (c *CIDR) Subnets(ones int) ([]*CIDR, error) {
+	if ones <= c.ones {
+		return []*CIDR{c}, nil
+	}
+	if ones-c.ones > maxSplitDepth {
+		return nil, fmt.Errorf("splitting %s into /%d would produce 2^%d networks", c, ones, ones-c.ones)
+	}
+
+	count := 1 << (ones - c.ones)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(c.bits-ones)) //nolint:gosec // G115: ones is bounded [0, bits]
+	start := ipToBigInt(c.ip)
+
+	subnets := make([]*CIDR, 0, count)
+	for i := 0; i < count; i++ {
+		subnet := newCIDR(bigIntToIP(start, c.bits), ones, c.bits)
+		subnet.annotations, subnet.omittedAnnotations = c.annotations, c.omittedAnnotations
+		subnets = append(subnets, subnet)
+		start.Add(start, step)
+	}
+	return subnets, nil
+}
+
+// newCIDR builds the network of the given prefix length containing ip.
+func newCIDR(ip net.IP, ones, bits int) *CIDR {
+	mask := net.CIDRMask(ones, bits)
+	network := ip.Mask(mask)
+
+	return &CIDR{
+		net: &net.IPNet{
+			IP:   network,
+			Mask: mask,
+		},
+		ip:   network,
+		ones: ones,
+		bits: bits,
+	}
+}
+
+// bounds returns the first and last addresses of c as integers.
+func (c *CIDR) bounds() (first, last *big.Int) {
+	first = ipToBigInt(c.ip)
+	size := new(big.Int).Lsh(big.NewInt(1), uint(c.bits-c.ones)) //nolint:gosec // G115: ones is bounded [0, bits]
+	last = new(big.Int).Add(first, size)
+	last.Sub(last, big.NewInt(1))
+	return first, last
+}
+
+// adjacentTo returns true if next starts immediately after the end of c.
+func (c *CIDR) adjacentTo(next *CIDR) bool {
+	if c.bits != next.bits {
+		return false
+	}
+	_, last := c.bounds()
+	first, _ := next.bounds()
+	return new(big.Int).Add(last, big.NewInt(1)).Cmp(first) == 0
+}
+
+// Netmask returns the network mask in dotted form, e.g. 255.255.255.0.
+func (c *CIDR) Netmask() net.IP {
+	return net.IP(net.CIDRMask(c.ones, c.bits))
+}
+
+// Wildcard returns the inverse (Cisco wildcard) mask, e.g. 0.0.0.255.
+func (c *CIDR) Wildcard() net.IP {
+	mask := net.CIDRMask(c.ones, c.bits)
+	wildcard := make(net.IP, len(mask))
+	for i, b := range mask {
+		wildcard[i] = ^b
+	}
+	return wildcard
+}
+
+func (c *CIDR) String() string {
+	return c.net.String()
+}
+
+// Input notations selected with -input-format.
+const (
+	inputCIDR     = "cidr"
+	inputWildcard = "wildcard"
+	inputRange    = "range"
+	inputNetmask  = "netmask"
+
+	inputCSV  = "csv"
+	inputTSV  = "tsv"
+	inputJSON = "json"
+)
+
+// inputs is the input format registry, holding the built-in notations in
+// the order they are detected in. Plain CIDR notation is the fallback.
+var inputs = builtinInputs()
+
+// builtinInputs registers the built-in input notations.
+func builtinInputs() *registry[InputParser] {
+	r := newRegistry[InputParser]("input")
+	r.register(inputNetmask, lineParser{
+		// Netmask notation is an address and mask separated by a space
+		detect: func(line string) bool {
+			parts := strings.Fields(line)
+			return strings.Contains(line, " ") && len(parts) == 2 && !strings.ContainsAny(parts[0], "/-*")
+		},
+		parse: func(line string) ([]*CIDR, error) {
+			parts := strings.Fields(line)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid netmask format %q: expected address and mask", line)
+			}
+			return parseNetmask(parts[0], parts[1])
+		},
+	})
+	r.register(inputWildcard, lineParser{
+		detect: func(line string) bool { return strings.Contains(firstField(line), "*") },
+		parse:  func(line string) ([]*CIDR, error) { return parseWildcard(firstField(line)) },
+	})
+	r.register(inputRange, lineParser{
+		// IPv6 addresses don't use dash, so any dash is a range indicator
+		detect: func(line string) bool { return strings.Contains(firstField(line), "-") },
+		parse:  func(line string) ([]*CIDR, error) { return parseRange(firstField(line)) },
+	})
+	r.register(inputCIDR, lineParser{
+		detect: func(string) bool { return true },
+		parse:  parseCIDRToSlice,
+	})
+	return r
+}
+
+// readers is the document input format registry.
+var readers = builtinReaders()
+
+// builtinReaders registers the built-in document input formats.
+func builtinReaders() *registry[InputReader] {
+	r := newRegistry[InputReader]("input")
+	r.register(inputCSV, csvReader{comma: ','})
+	r.register(inputTSV, csvReader{comma: '\t'})
+	r.register(inputJSON, jsonReader{})
+	return r
+}
+
+// parseInput parses various IP range formats and returns one or more CIDRs.
+// Supported formats:
+//   - Standard CIDR: 192.168.1.0/24
+//   - Plain IP: 192.168.1.1
+//   - Wildcard: 192.168.1.* or 2001:db8::*
+//   - Dash range: 192.168.1.1-192.168.1.255 or 2001:db8::1-2001:db8::ff
+//   - Short range: 192.168.1.0-255
+//   - Netmask: 192.168.1.0 255.255.255.0
+func parseInput(s string) ([]*CIDR, error) {
+	return parseInputAs(s, "")
+}
+
+// parseInputAs parses s in the named input format, or detects the format
+// when name is empty.
+func parseInputAs(s, name string) ([]*CIDR, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") || strings.HasPrefix(s, ";") {
+		return nil, nil // skip empty lines and comments
+	}
+
+	// Extract just the IP/CIDR part (handle "IP/CIDR ; comment" format)
+	// But preserve spaces for netmask format detection
+	if idx := strings.IndexAny(s, ";#"); idx != -1 {
+		s = strings.TrimSpace(s[:idx])
+	}
+	if s == "" {
+		return nil, nil
+	}
+
+	parser, err := inputParserFor(name, s)
+	if err != nil {
+		return nil, err
+	}
+	return parser.Parse(s)
+}
+
+// firstField returns s up to the first space or tab, dropping any
+// trailing content.
+func firstField(s string) string {
+	if idx := strings.IndexAny(s, " \t"); idx != -1 {
+		return s[:idx]
+	}
+	return s
+}
+
+// parseWildcard converts wildcard notation to CIDR.
+// Examples:
+//   - 192.168.1.* → 192.168.1.0/24
+//   - 192.168.*.* → 192.168.0.0/16
+//   - 2001:db8::* → 2001:db8::/32 (everything after :: is wildcarded)
+func parseWildcard(s string) ([]*CIDR, error) {
+	// IPv6 wildcard
+	if strings.Contains(s, ":") {
+		return parseIPv6Wildcard(s)
+	}
+
+	// IPv4 wildcard: count asterisks and validate format
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid wildcard format %q: expected 4 octets", s)
+	}
+
+	// Find first asterisk and ensure all following are asterisks
+	firstWildcard := -1
+	for i, p := range parts {
+		if p == "*" {
+			if firstWildcard == -1 {
+				firstWildcard = i
+			}
+		} else if firstWildcard != -1 {
+			return nil, fmt.Errorf("invalid wildcard format %q: wildcard must be at end", s)
+		}
+	}
+
+	if firstWildcard == -1 {
+		return nil, fmt.Errorf("invalid wildcard format %q: no wildcard found", s)
+	}
+
+	// Build base IP by replacing * with 0
+	for i := firstWildcard; i < 4; i++ {
+		parts[i] = "0"
+	}
+	baseIP := strings.Join(parts, ".")
+
+	// Calculate prefix length (8 bits per non-wildcard octet)
+	prefixLen := firstWildcard * 8
+
+	cidrStr := fmt.Sprintf("%s/%d", baseIP, prefixLen)
+	return parseCIDRToSlice(cidrStr)
+}
+
+// parseIPv6Wildcard handles IPv6 wildcard notation.
+// The wildcard * replaces everything after the last specified segment.
+// Examples:
+//   - 2001:db8::* → 2001:db8::/32
+//   - 2001:db8:abcd::* → 2001:db8:abcd::/48
+func parseIPv6Wildcard(s string) ([]*CIDR, error) {
+	if !strings.HasSuffix(s, "*") {
+		return nil, fmt.Errorf("invalid IPv6 wildcard format %q: wildcard must be at end", s)
+	}
+
+	// Remove the trailing *
+	s = strings.TrimSuffix(s, "*")
+
+	// Handle :: notation - count specified segments
+	var prefixLen int
+	if strings.Contains(s, "::") {
+		// For patterns like "2001:db8::*", count segments before ::
+		parts := strings.Split(s, "::")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid IPv6 wildcard format %q: contains multiple double-colons", s)
+		}
+
+		segments := 0
+		if parts[0] != "" {
+			segments = len(strings.Split(parts[0], ":"))
+		}
+		// Each segment is 16 bits
+		prefixLen = segments * 16
+
+		// Build CIDR notation - use the prefix part
+		var prefix string
+		if parts[0] != "" {
+			prefix = parts[0]
+		}
+		cidrStr := fmt.Sprintf("%s::/%d", prefix, prefixLen)
+		return parseCIDRToSlice(cidrStr)
+	}
+
+	// No :: notation - remove trailing colons and count segments
+	s = strings.TrimSuffix(s, ":")
+	segments := len(strings.Split(s, ":"))
+	prefixLen = segments * 16
+
+	// Build CIDR notation
+	cidrStr := fmt.Sprintf("%s::/%d", s, prefixLen)
+	return parseCIDRToSlice(cidrStr)
+}
+
+// parseRange handles dash range notation.
+// Examples:
+//   - 192.168.1.1-192.168.1.255 (full range)
+//   - 192.168.1.0-255 (short range - last octet only)
+//   - 2001:db8::1-2001:db8::ff (IPv6 range)
+func parseRange(s string) ([]*CIDR, error) {
+	dashIdx := strings.LastIndex(s, "-")
+	if dashIdx == -1 {
+		return nil, fmt.Errorf("invalid range format %q: no dash found", s)
+	}
+
+	startStr := s[:dashIdx]
+	endStr := s[dashIdx+1:]
+
+	// Detect if this is a short range (last octet only)
+	isIPv6 := strings.Contains(startStr, ":")
+
+	if !isIPv6 && !strings.Contains(endStr, ".") {
+		// Short range format: 192.168.1.0-255
+		return parseShortRange(startStr, endStr)
+	}
+
+	// Full range format
+	startIP := net.ParseIP(startStr)
+	endIP := net.ParseIP(endStr)
+
+	if startIP == nil {
+		return nil, fmt.Errorf("invalid range start IP %q", startStr)
+	}
+	if endIP == nil {
+		return nil, fmt.Errorf("invalid range end IP %q", endStr)
+	}
+
+	// Normalise to same format
+	if isIPv6 {
+		startIP = startIP.To16()
+		endIP = endIP.To16()
+	} else {
+		startIP = startIP.To4()
+		endIP = endIP.To4()
+		if startIP == nil || endIP == nil {
+			return nil, fmt.Errorf("invalid IPv4 range %q", s)
+		}
+	}
+
+	return rangeToCIDRs(startIP, endIP)
+}
+
+// parseShortRange handles short range notation where only the last octet varies.
+// Example: 192.168.1.0-255 → 192.168.1.0 to 192.168.1.255
+func parseShortRange(startStr, endOctetStr string) ([]*CIDR, error) {
+	startIP := net.ParseIP(startStr)
+	if startIP == nil {
+		return nil, fmt.Errorf("invalid short range start IP %q", startStr)
+	}
+
+	startIP = startIP.To4()
+	if startIP == nil {
+		return nil, fmt.Errorf("short range only supports IPv4 %q", startStr)
+	}
+
+	// Parse end octet
+	var endOctet int
+	_, err := fmt.Sscanf(endOctetStr, "%d", &endOctet)
+	if err != nil || endOctet < 0 || endOctet > 255 {
+		return nil, fmt.Errorf("invalid short range end octet %q", endOctetStr)
+	}
+
+	// Build end IP
+	endIP := make(net.IP, 4)
+	copy(endIP, startIP)
+	endIP[3] = byte(endOctet) //nolint:gosec // G602: endIP is always 4 bytes (created above)
+
+	return rangeToCIDRs(startIP, endIP)
+}
+
+// parseNetmask handles netmask notation.
+// Example: 192.168.1.0 255.255.255.0 → 192.168.1.0/24
+func parseNetmask(ipStr, maskStr string) ([]*CIDR, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP in netmask notation %q", ipStr)
+	}
+
+	mask := net.ParseIP(maskStr)
+	if mask == nil {
+		return nil, fmt.Errorf("invalid netmask %q", maskStr)
+	}
+
+	// Convert to IPv4 if possible
+	ip4 := ip.To4()
+	mask4 := mask.To4()
+
+	var prefixLen int
+	if ip4 != nil && mask4 != nil {
+		// IPv4 netmask
+		ipMask := net.IPMask(mask4)
+		ones, bits := ipMask.Size()
+		if bits == 0 {
+			return nil, fmt.Errorf("invalid netmask %q: not a valid mask", maskStr)
+		}
+		// Validate mask is contiguous
+		if !isContiguousMask(mask4) {
+			return nil, fmt.Errorf("invalid netmask %q: non-contiguous mask", maskStr)
+		}
+		prefixLen = ones
+		ip = ip4
+	} else {
+		// IPv6 netmask (rare but supported)
+		ip = ip.To16()
+		mask = mask.To16()
+		ipMask := net.IPMask(mask)
+		ones, bits := ipMask.Size()
+		if bits == 0 {
+			return nil, fmt.Errorf("invalid IPv6 netmask %q", maskStr)
+		}
+		if !isContiguousMask(mask) {
+			return nil, fmt.Errorf("invalid netmask %q: non-contiguous mask", maskStr)
+		}
+		prefixLen = ones
+	}
+
+	cidrStr := fmt.Sprintf("%s/%d", ip.String(), prefixLen)
+	return parseCIDRToSlice(cidrStr)
+}
+
+// isContiguousMask checks if a netmask has contiguous 1-bits.
+// A valid mask like 255.255.255.0 is contiguous, 255.255.254.1 is not.
+func isContiguousMask(mask net.IP) bool {
+	// Convert to binary and check for pattern: 1111...0000
+	foundZero := false
+	for _, b := range mask {
+		for i := 7; i >= 0; i-- {
+			bit := (b >> i) & 1
+			if bit == 0 {
+				foundZero = true
+			} else if foundZero {
+				// Found a 1 after a 0 - not contiguous
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rangeToCIDRs converts an IP range to the minimal set of CIDRs.
+// Algorithm:
+// 1. Convert start/end IPs to big integers
+// 2. Find largest CIDR that fits within range starting at current position
+// 3. Add to result, advance position
+// 4. Repeat until range covered
+func rangeToCIDRs(startIP, endIP net.IP) ([]*CIDR, error) {
+	// Validate range direction
+	if compareIPs(startIP, endIP) > 0 {
+		return nil, fmt.Errorf("invalid range: start %s > end %s", startIP, endIP)
+	}
+
+	// Determine IP version
+	bits := 32
+	if len(startIP) == 16 && startIP.To4() == nil {
+		bits = 128
+	} else {
+		startIP = startIP.To4()
+		endIP = endIP.To4()
+		if startIP == nil || endIP == nil {
+			return nil, fmt.Errorf("mismatched IP versions in range")
+		}
+	}
+
+	start := ipToBigInt(startIP)
+	end := ipToBigInt(endIP)
+
+	var cidrs []*CIDR
+	one := big.NewInt(1)
+
+	for start.Cmp(end) <= 0 {
+		// Find the largest CIDR block that:
+		// 1. Starts at 'start'
+		// 2. Doesn't exceed 'end'
+
+		// Find how many trailing zeros in start (determines max alignment)
+		maxSize := trailingZeros(start, bits)
+
+		// Find the largest block that doesn't exceed end
+		// Size of block = 2^(bits - prefix)
+		remaining := new(big.Int).Sub(end, start)
+		remaining.Add(remaining, one) // +1 because range is inclusive
+
+		for maxSize > 0 {
+			blockSize := new(big.Int).Lsh(one, uint(maxSize))
+			if blockSize.Cmp(remaining) <= 0 {
+				break
+			}
+			maxSize--
+		}
+
+		// Create CIDR
+		prefixLen := bits - maxSize
+		ip := bigIntToIP(start, bits)
+		cidrStr := fmt.Sprintf("%s/%d", ip.String(), prefixLen)
+		cidr, err := parseCIDR(cidrStr)
+		if err != nil {
+			return nil, fmt.Errorf("internal error creating CIDR %s: %v", cidrStr, err)
+		}
+		cidrs = append(cidrs, cidr)
+
+		// Advance start by block size
+		blockSize := new(big.Int).Lsh(one, uint(maxSize)) //nolint:gosec // G115: maxSize is bounded [0, 128]
+		start.Add(start, blockSize)
+	}
+
+	return cidrs, nil
+}
+
+// ipToBigInt converts an IP address to a big.Int.
+func ipToBigInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(ip)
+}
+
+// bigIntToIP converts a big.Int back to an IP address.
+func bigIntToIP(n *big.Int, bits int) net.IP {
+	bytes := n.Bytes()
+	length := bits / 8
+
+	// Pad with leading zeros if necessary
+	if len(bytes) < length {
+		padded := make([]byte, length)
+		copy(padded[length-len(bytes):], bytes)
+		bytes = padded
+	}
+
+	return net.IP(bytes)
+}
+
+// trailingZeros returns the number of trailing zero bits in n.
+// For IP alignment, this determines the maximum CIDR block size.
+func trailingZeros(n *big.Int, maxBits int) int {
+	if n.Sign() == 0 {
+		return maxBits // Zero has all trailing zeros up to max
+	}
+
+	count := 0
+	one := big.NewInt(1)
+	temp := new(big.Int).Set(n)
+
+	for count < maxBits {
+		if temp.Bit(0) == 1 {
+			break
+		}
+		temp.Rsh(temp, 1)
+		count++
+	}
+
+	// Clamp to maxBits (for /0 equivalent)
+	_ = one // silence unused warning
+	if count > maxBits {
+		count = maxBits
+	}
+	return count
+}
+
+// parseCIDRToSlice is a helper that wraps parseCIDR result in a slice.
+func parseCIDRToSlice(s string) ([]*CIDR, error) {
+	cidr, err := parseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	if cidr == nil {
+		return nil, nil
+	}
+	return []*CIDR{cidr}, nil
+}
+
+// ipToUint32 converts IPv4 to uint32 for sorting
+func ipToUint32(ip net.IP) uint32 {
+	ip = ip.To4()
+	if ip == nil {
+		return 0
+	}
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+// run aggregates input with the default options.
+func run(input io.Reader, output, errOutput io.Writer) error {
+	return Run(input, output, errOutput, Options{})
+}
+
+// Run reads networks from input, aggregates them and writes them to output
+// as opts selects. Unparseable lines and other problems are reported to
+// errOutput. Options built by hand should be checked with Validate first.
+func Run(input io.Reader, output, errOutput io.Writer, opts Options) error {
+	ipv4, ipv6, err := readNetworks(input, errOutput, opts)
+	if err != nil {
+		return err
+	}
+
+	// Apply NetworkPolicy exclusions before output
+	if opts.Exclude != "" {
+		if ipv4, ipv6, err = applyExclusions(ipv4, ipv6, errOutput, opts); err != nil {
+			return err
+		}
+	}
+
+	// Compare against the previously announced list in diff mode
+	if opts.Diff != "" {
+		return runDiff(output, errOutput, ipv4, ipv6, opts)
+	}
+
+	if len(ipv4) == 0 && len(ipv6) == 0 {
+		return nil
+	}
+
+	// Output results
+	if err := writeOutput(output, ipv4, ipv6, opts); err != nil {
+		_, _ = fmt.Fprintf(errOutput, "error writing output: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// readNetworks parses input, line by line or with the document reader chosen
+// by -input-format, and returns the processed IPv4 and IPv6 networks. Parse
+// errors are reported to errOutput and the entry skipped.
+func readNetworks(input io.Reader, errOutput io.Writer, opts Options) (ipv4, ipv6 []*CIDR, err error) {
+	var cidrs []*CIDR
+	if reader, ok := readers.entries[opts.InputFormat]; ok {
+		cidrs, err = reader.ReadNetworks(input, errOutput, opts)
+	} else {
+		cidrs, err = readLines(input, errOutput, opts)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(errOutput, "error reading input: %v\n", err)
+		return nil, nil, err
+	}
+
+	if len(cidrs) == 0 {
+		return nil, nil, nil
+	}
+
+	// Separate IPv4 and IPv6
+	for _, c := range cidrs {
+		if c.bits == 32 {
+			ipv4 = append(ipv4, c)
+		} else {
+			ipv6 = append(ipv6, c)
+		}
+	}
+
+	// Cap prefix lengths before aggregation so widened entries can merge
+	ipv4 = capPrefixLength(ipv4, opts.MaxPrefix4, opts.DropLonger)
+	ipv6 = capPrefixLength(ipv6, opts.MaxPrefix6, opts.DropLonger)
+
+	// Process each separately
+	ipv4 = processNetworks(ipv4)
+	ipv6 = processNetworks(ipv6)
+
+	// Split broad networks after aggregation so they are not merged back
+	if ipv4, err = splitNetworks(ipv4, opts.MinPrefix4); err != nil {
+		_, _ = fmt.Fprintf(errOutput, "error: %v\n", err)
+		return nil, nil, err
+	}
+	if ipv6, err = splitNetworks(ipv6, opts.MinPrefix6); err != nil {
+		_, _ = fmt.Fprintf(errOutput, "error: %v\n", err)
+		return nil, nil, err
+	}
+
+	return ipv4, ipv6, nil
+}
+
+func processNetworks(cidrs []*CIDR) []*CIDR {
+	if len(cidrs) == 0 {
+		return cidrs
+	}
+
+	// Sort by IP address, then by prefix length (smaller prefix = larger network first)
+	sort.Slice(cidrs, func(i, j int) bool {
+		cmpIP := compareIPs(cidrs[i].ip, cidrs[j].ip)
+		if cmpIP != 0 {
+			return cmpIP < 0
+		}
+		return cidrs[i].ones < cidrs[j].ones
+	})
+
+	// Remove overlaps (if A contains B, remove B)
+	cidrs = removeOverlaps(cidrs)
+
+	// Aggregate adjacent networks
+	cidrs = aggregateNetworks(cidrs)
+
+	return cidrs
+}
+
+// capPrefixLength widens every network more specific than maxOnes to its
+// covering prefix of that length, or drops it when drop is set.
+// A maxOnes of zero leaves the list untouched.
+func capPrefixLength(cidrs []*CIDR, maxOnes int, drop bool) []*CIDR {
+	if maxOnes == 0 {
+		return cidrs
+	}
+
+	result := make([]*CIDR, 0, len(cidrs))
+	for _, c := range cidrs {
+		if c.ones <= maxOnes {
+			result = append(result, c)
+			continue
+		}
+		if drop {
+			continue
+		}
+		result = append(result, c.Supernet(maxOnes))
+	}
+	return result
+}
+
+// splitNetworks splits every network broader than minOnes into subnets of
+// that length. A minOnes of zero leaves the list untouched.
+func splitNetworks(cidrs []*CIDR, minOnes int) ([]*CIDR, error) {
+	if minOnes == 0 {
+		return cidrs, nil
+	}
+
+	result := make([]*CIDR, 0, len(cidrs))
+	for _, c := range cidrs {
+		subnets, err := c.Subnets(minOnes)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, subnets...)
+	}
+	return result, nil
+}
+
+func compareIPs(a, b net.IP) int {
+	a = a.To16()
+	b = b.To16()
+	for i := 0; i < len(a); i++ {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func removeOverlaps(cidrs []*CIDR) []*CIDR {
+	if len(cidrs) <= 1 {
+		return cidrs
+	}
+
+	result := []*CIDR{cidrs[0]}
+	for i := 1; i < len(cidrs); i++ {
+		current := result[len(result)-1]
+		next := cidrs[i]
+
+		// If current contains next, skip next (it's redundant)
+		// but keep its annotations
+		if current.Contains(next) {
+			current.annotations, current.omittedAnnotations = mergeAnnotations(current, next)
+			continue
+		}
+		result = append(result, next)
+	}
+	return result
+}
+
+// readLines parses input one line at a time, reporting lines that fail to
+// parse to errOutput and skipping them.
+func readLines(input io.Reader, errOutput io.Writer, opts Options) ([]*CIDR, error) {
+	var cidrs []*CIDR
+	scanner := bufio.NewScanner(input)
+	annotate := opts.keepsAnnotations()
+
+	// Read all CIDRs from input (supporting multiple formats)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		parsed, err := parseInputAs(scanner.Text(), opts.InputFormat)
+		if err != nil {
+			_, _ = fmt.Fprintf(errOutput, "line %d: %v\n", lineNum, err)
+			continue
+		}
+		if annotation := lineAnnotation(scanner.Text()); annotate && annotation != "" {
+			for _, c := range parsed {
+				c.annotations = []string{annotation}
+			}
+		}
+		cidrs = append(cidrs, parsed...)
+	}
+	return cidrs, scanner.Err()
+}
+
+// lineAnnotation returns the trailing "; comment" or "# comment" of an input
+// line, or "" for lines without one and for lines that are only a comment.
+func lineAnnotation(line string) string {
+	line = strings.TrimSpace(line)
+	idx := strings.IndexAny(line, ";#")
+	if idx <= 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[idx+1:])
+}
+
+// maxAnnotations caps the annotations kept per network, so a network
+// aggregated from thousands of annotated lines only counts most of them.
+const maxAnnotations = 10
+
+// mergeAnnotations returns the annotations of a followed by those of b that
+// a does not already hold, at most maxAnnotations of them, and the number
+// of annotations left out. The inputs are not modified.
+func mergeAnnotations(a, b *CIDR) ([]string, int) {
+	omitted := a.omittedAnnotations + b.omittedAnnotations
+	if len(b.annotations) == 0 {
+		return a.annotations, omitted
+	}
+
+	merged := make([]string, len(a.annotations), maxAnnotations)
+	copy(merged, a.annotations)
+	seen := make(map[string]bool, maxAnnotations)
+	for _, annotation := range merged {
+		seen[annotation] = true
+	}
+	for _, annotation := range b.annotations {
+		switch {
+		case seen[annotation]:
+		case len(merged) == maxAnnotations:
+			omitted++
+		default:
+			seen[annotation] = true
+			merged = append(merged, annotation)
+		}
+	}
+	return merged, omitted
+}
+
+func aggregateNetworks(cidrs []*CIDR) []*CIDR {
+	changed := true
+	for changed {
+		changed = false
+		var newCIDRs []*CIDR
+
+		i := 0
+		for i < len(cidrs) {
+			if i+1 < len(cidrs) && cidrs[i].CanAggregate(cidrs[i+1]) {
+				// Combine into parent
+				newCIDRs = append(newCIDRs, cidrs[i].Aggregate(cidrs[i+1]))
+				i += 2
+				changed = true
+			} else {
+				newCIDRs = append(newCIDRs, cidrs[i])
+				i++
+			}
+		}
+		cidrs = newCIDRs
+
+		// Re-sort after aggregation (parent might now be adjacent to another network)
+		if changed {
+			sort.Slice(cidrs, func(i, j int) bool {
+				cmpIP := compareIPs(cidrs[i].ip, cidrs[j].ip)
+				if cmpIP != 0 {
+					return cmpIP < 0
+				}
+				return cidrs[i].ones < cidrs[j].ones
+			})
+		}
+	}
+	return cidrs
+}
@@ -0,0 +1,124 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// chunkStyle describes how a chunkable format is split: comment starts the
+// comment line labelling each section, in the format's own syntax, and ext
+// is the extension of numbered chunk files.
+type chunkStyle struct {
+	comment string
+	ext     string
+}
+
+// chunks returns the chunk style for a format with the given comment marker
+// and file extension.
+func chunks(comment, ext string) *chunkStyle {
+	return &chunkStyle{comment: comment, ext: ext}
+}
+
+// chunkNetworks partitions a sorted list into groups of at most size entries.
+// When contiguous is set, a new group is also started wherever there is a gap
+// in address space, so every group covers one unbroken range.
+func chunkNetworks(cidrs []*CIDR, size int, contiguous bool) [][]*CIDR {
+	var chunks [][]*CIDR
+	var current []*CIDR
+
+	for _, c := range cidrs {
+		if len(current) > 0 {
+			full := len(current) >= size
+			gap := contiguous && !current[len(current)-1].adjacentTo(c)
+			if full || gap {
+				chunks = append(chunks, current)
+				current = nil
+			}
+		}
+		current = append(current, c)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// writeChunks splits each address family into chunks and writes them either
+// as labelled sections on w or, when a chunk prefix is set, as numbered files.
+// Writers see the chunk number and the number of entries of the family
+// written before it, so numbering and names stay unique across chunks.
+func writeChunks(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	format, err := outputFormatFor(opts.Format)
+	if err != nil {
+		return err
+	}
+	style := format.chunks
+	if style == nil {
+		return fmt.Errorf("-format %s cannot be split with -chunk-size", opts.Format)
+	}
+
+	families := []struct {
+		name  string
+		cidrs []*CIDR
+	}{
+		{name: "ipv4", cidrs: ipv4},
+		{name: "ipv6", cidrs: ipv6},
+	}
+
+	for _, family := range families {
+		chunks := chunkNetworks(family.cidrs, opts.ChunkSize, opts.ChunkContiguous)
+		chunkOpts := opts
+		chunkOpts.chunkStart = 0
+		for i, chunk := range chunks {
+			chunkOpts.chunk = i + 1
+			var err error
+			if opts.ChunkPrefix != "" {
+				err = writeChunkFile(chunkFileName(opts.ChunkPrefix, family.name, i+1, style.ext), family.name, chunk, chunkOpts)
+			} else {
+				err = writeChunkSection(w, style, family.name, i+1, len(chunks), chunk, chunkOpts)
+			}
+			if err != nil {
+				return err
+			}
+			chunkOpts.chunkStart += len(chunk)
+		}
+	}
+	return nil
+}
+
+// chunkFileName returns the numbered file name for a chunk,
+// e.g. "blocklist-ipv4-001.txt".
+func chunkFileName(prefix, family string, n int, ext string) string {
+	return fmt.Sprintf("%s-%s-%03d%s", prefix, family, n, ext)
+}
+
+// writeChunkSection writes a chunk to w preceded by a comment label.
+func writeChunkSection(w io.Writer, style *chunkStyle, family string, n, total int, chunk []*CIDR, opts Options) error {
+	if _, err := fmt.Fprintf(w, "%s %s chunk %d/%d (%d entries)\n", style.comment, family, n, total, len(chunk)); err != nil {
+		return err
+	}
+	return writeChunk(w, family, chunk, opts)
+}
+
+// writeChunkFile writes a chunk to its own file, replacing any existing file.
+func writeChunkFile(name, family string, chunk []*CIDR, opts Options) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating chunk file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return writeChunk(f, family, chunk, opts)
+}
+
+// writeChunk writes a single-family chunk in the selected output format.
+func writeChunk(w io.Writer, family string, chunk []*CIDR, opts Options) error {
+	if family == "ipv4" {
+		return writeFormatted(w, chunk, nil, opts)
+	}
+	return writeFormatted(w, nil, chunk, opts)
+}
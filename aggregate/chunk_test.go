@@ -0,0 +1,223 @@
+package aggregate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChunkNetworks(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []string
+		size       int
+		contiguous bool
+		want       [][]string
+	}{
+		{
+			name:  "Even split",
+			input: []string{"10.0.0.0/24", "10.0.2.0/24", "10.0.4.0/24", "10.0.6.0/24"},
+			size:  2,
+			want:  [][]string{{"10.0.0.0/24", "10.0.2.0/24"}, {"10.0.4.0/24", "10.0.6.0/24"}},
+		},
+		{
+			name:  "Remainder in last chunk",
+			input: []string{"10.0.0.0/24", "10.0.2.0/24", "10.0.4.0/24"},
+			size:  2,
+			want:  [][]string{{"10.0.0.0/24", "10.0.2.0/24"}, {"10.0.4.0/24"}},
+		},
+		{
+			name:  "Size larger than list",
+			input: []string{"10.0.0.0/24", "10.0.2.0/24"},
+			size:  10,
+			want:  [][]string{{"10.0.0.0/24", "10.0.2.0/24"}},
+		},
+		{
+			name:       "Contiguous breaks at gaps",
+			input:      []string{"10.0.0.0/24", "10.0.1.0/25", "10.0.1.128/26", "10.0.3.0/24"},
+			size:       10,
+			contiguous: true,
+			want:       [][]string{{"10.0.0.0/24", "10.0.1.0/25", "10.0.1.128/26"}, {"10.0.3.0/24"}},
+		},
+		{
+			name:       "Contiguous still honours size",
+			input:      []string{"10.0.0.0/24", "10.0.1.0/25", "10.0.1.128/26"},
+			size:       2,
+			contiguous: true,
+			want:       [][]string{{"10.0.0.0/24", "10.0.1.0/25"}, {"10.0.1.128/26"}},
+		},
+		{
+			name:  "Empty",
+			input: []string{},
+			size:  2,
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cidrs []*CIDR
+			for _, s := range tt.input {
+				c, _ := parseCIDR(s)
+				cidrs = append(cidrs, c)
+			}
+
+			got := chunkNetworks(cidrs, tt.size, tt.contiguous)
+
+			if len(got) != len(tt.want) {
+				t.Errorf("chunkNetworks() returned %d chunks, want %d", len(got), len(tt.want))
+				return
+			}
+
+			for i, chunk := range got {
+				var gotStrs []string
+				for _, c := range chunk {
+					gotStrs = append(gotStrs, c.String())
+				}
+				if strings.Join(gotStrs, " ") != strings.Join(tt.want[i], " ") {
+					t.Errorf("chunkNetworks()[%d] = %v, want %v", i, gotStrs, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunWithChunkSections(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n2001:db8::/64\n")
+	var output, errOutput bytes.Buffer
+
+	err := Run(input, &output, &errOutput, Options{ChunkSize: 2})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	want := "# ipv4 chunk 1/2 (2 entries)\n10.0.0.0/24\n10.0.2.0/24\n" +
+		"# ipv4 chunk 2/2 (1 entries)\n10.0.4.0/24\n" +
+		"# ipv6 chunk 1/1 (1 entries)\n2001:db8::/64\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
+
+func TestRunWithChunkFiles(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "blocklist")
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n2001:db8::/64\n")
+	var output, errOutput bytes.Buffer
+
+	err := Run(input, &output, &errOutput, Options{ChunkSize: 2, ChunkPrefix: prefix})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if output.String() != "" {
+		t.Errorf("Run() wrote to output with chunk files: %q", output.String())
+	}
+
+	wantFiles := map[string]string{
+		"blocklist-ipv4-001.txt": "10.0.0.0/24\n10.0.2.0/24\n",
+		"blocklist-ipv4-002.txt": "10.0.4.0/24\n",
+		"blocklist-ipv6-001.txt": "2001:db8::/64\n",
+	}
+	for name, want := range wantFiles {
+		got, err := os.ReadFile(filepath.Join(filepath.Dir(prefix), name))
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRunWithChunkFilesError(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "missing", "blocklist")
+	input := strings.NewReader("10.0.0.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	err := Run(input, &output, &errOutput, Options{ChunkSize: 2, ChunkPrefix: prefix})
+	if err == nil {
+		t.Error("Run() expected error for unwritable chunk file, got nil")
+	}
+}
+
+func TestRunWithChunkSectionsInFormatSyntax(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n2001:db8::/64\n")
+	var output, errOutput bytes.Buffer
+
+	err := Run(input, &output, &errOutput, Options{Format: formatFRRStatic, ChunkSize: 1})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	want := "! ipv4 chunk 1/2 (1 entries)\nip route 10.0.0.0/24 blackhole\n" +
+		"! ipv4 chunk 2/2 (1 entries)\nip route 10.0.2.0/24 blackhole\n" +
+		"! ipv6 chunk 1/1 (1 entries)\nipv6 route 2001:db8::/64 blackhole\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
+
+func TestRunWithChunkFilesFormatExtension(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "relay")
+	input := strings.NewReader("10.0.0.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	err := Run(input, &output, &errOutput, Options{Format: formatPostfixCIDR, ChunkSize: 10, ChunkPrefix: prefix})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(prefix + "-ipv4-001.cidr")
+	if err != nil {
+		t.Fatalf("reading chunk file: %v", err)
+	}
+	if string(got) != "10.0.0.0/24 OK\n" {
+		t.Errorf("chunk file = %q", got)
+	}
+}
+
+func TestRunWithChunkedDocumentFormat(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	err := Run(input, &output, &errOutput, Options{Format: formatAWSSecurityGroup, ChunkSize: 1})
+	if err == nil {
+		t.Fatal("Run() expected error for a chunked document format")
+	}
+	if output.Len() != 0 {
+		t.Errorf("Run() wrote partial output %q", output.String())
+	}
+}
+
+func TestWriteChunksNumbering(t *testing.T) {
+	withRegistries(t)
+	outputs.register("numbered", outputFormat{
+		writer: FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, opts Options) error {
+			_, err := fmt.Fprintf(w, "chunk=%d start=%d entries=%d\n", opts.chunk, opts.chunkStart, len(cidrs))
+			return err
+		}),
+		chunks: chunks("//", ".txt"),
+	})
+
+	ipv4 := mustParseCIDRs(t, "10.0.0.0/24", "10.0.2.0/24", "10.0.4.0/24")
+	ipv6 := mustParseCIDRs(t, "2001:db8::/64")
+	var output bytes.Buffer
+
+	if err := writeChunks(&output, ipv4, ipv6, Options{Format: "numbered", ChunkSize: 2}); err != nil {
+		t.Fatalf("writeChunks() unexpected error: %v", err)
+	}
+
+	// The empty family of each chunk is written too, so every chunk
+	// produces two lines.
+	want := "// ipv4 chunk 1/2 (2 entries)\nchunk=1 start=0 entries=2\nchunk=1 start=0 entries=0\n" +
+		"// ipv4 chunk 2/2 (1 entries)\nchunk=2 start=2 entries=1\nchunk=2 start=2 entries=0\n" +
+		"// ipv6 chunk 1/1 (1 entries)\nchunk=1 start=0 entries=0\nchunk=1 start=0 entries=1\n"
+	if output.String() != want {
+		t.Errorf("writeChunks() output = %q, want %q", output.String(), want)
+	}
+}
@@ -0,0 +1,55 @@
+package aggregate_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"aggregate-cidr/aggregate"
+)
+
+func ExampleRun() {
+	opts := aggregate.Options{Format: "cisco-prefix-list", Name: "office"}
+	if err := opts.Validate(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	input := strings.NewReader("192.168.0.0/24\n192.168.1.0/24\n")
+	if err := aggregate.Run(input, os.Stdout, os.Stderr, opts); err != nil {
+		fmt.Println(err)
+	}
+	// Output: ip prefix-list office seq 5 permit 192.168.0.0/23
+}
+
+func ExampleRegisterInputFormat() {
+	// ipset save files list members as "add SET MEMBER"
+	aggregate.RegisterInputFormat("ipset-save", ipsetSaveParser{})
+
+	input := strings.NewReader("create blocklist hash:net\nadd blocklist 10.0.0.0/25\nadd blocklist 10.0.0.128/25\n")
+	if err := aggregate.Run(input, os.Stdout, io.Discard, aggregate.Options{InputFormat: "ipset-save"}); err != nil {
+		fmt.Println(err)
+	}
+	// Output: 10.0.0.0/24
+}
+
+// ipsetSaveParser reads the members of ipset save output, skipping the
+// create lines.
+type ipsetSaveParser struct{}
+
+func (ipsetSaveParser) Detect(line string) bool {
+	return strings.HasPrefix(line, "add ") || strings.HasPrefix(line, "create ")
+}
+
+func (ipsetSaveParser) Parse(line string) ([]*aggregate.CIDR, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "add" {
+		return nil, nil
+	}
+	c, err := aggregate.ParseCIDR(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	return []*aggregate.CIDR{c}, nil
+}
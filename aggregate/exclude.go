@@ -0,0 +1,126 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// applyExclusions applies the NetworkPolicy exclusions listed in
+// opts.Exclude. Allowed networks keep the excluded parts in their except
+// lists, while denied networks have them removed so egress to them stays
+// allowed.
+func applyExclusions(ipv4, ipv6 []*CIDR, errOutput io.Writer, opts Options) (newIPv4, newIPv6 []*CIDR, err error) {
+	excl4, excl6, err := readNetworksFile(opts.Exclude, "exclude", errOutput, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.RuleAction() == "permit" {
+		return attachExceptions(ipv4, excl4), attachExceptions(ipv6, excl6), nil
+	}
+
+	if newIPv4, err = excludeNetworks(ipv4, excl4); err != nil {
+		return nil, nil, err
+	}
+	if newIPv6, err = excludeNetworks(ipv6, excl6); err != nil {
+		return nil, nil, err
+	}
+	return newIPv4, newIPv6, nil
+}
+
+// readNetworksFile opens the named file and returns its processed networks.
+// The kind describes the file's role in error messages.
+func readNetworksFile(name, kind string, errOutput io.Writer, opts Options) (ipv4, ipv6 []*CIDR, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOutput, "error opening %s file: %v\n", kind, err)
+		return nil, nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	// Exclusion and diff lists are plain network lists even when the main
+	// input is a document such as CSV.
+	if isDocumentInput(opts.InputFormat) {
+		opts.InputFormat = ""
+	}
+	return readNetworks(f, errOutput, opts)
+}
+
+// excludeNetworks subtracts exclusions from a sorted, aggregated list of
+// networks of one family. Networks partly covered by an exclusion are
+// replaced by the minimal set of CIDRs covering what remains.
+func excludeNetworks(cidrs, exclusions []*CIDR) ([]*CIDR, error) {
+	if len(exclusions) == 0 {
+		return cidrs, nil
+	}
+
+	one := big.NewInt(1)
+	var result []*CIDR
+	for _, c := range cidrs {
+		inside, covered := overlappingExclusions(c, exclusions)
+		if covered {
+			continue
+		}
+		if len(inside) == 0 {
+			result = append(result, c)
+			continue
+		}
+
+		// Walk the network, keeping the gaps between excluded blocks
+		first := len(result)
+		start, last := c.bounds()
+		for _, e := range inside {
+			eFirst, eLast := e.bounds()
+			if start.Cmp(eFirst) < 0 {
+				gap, err := rangeToCIDRs(bigIntToIP(start, c.bits), bigIntToIP(new(big.Int).Sub(eFirst, one), c.bits))
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, gap...)
+			}
+			start = new(big.Int).Add(eLast, one)
+		}
+		if start.Cmp(last) <= 0 {
+			gap, err := rangeToCIDRs(bigIntToIP(start, c.bits), bigIntToIP(last, c.bits))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, gap...)
+		}
+		for _, gap := range result[first:] {
+			gap.annotations, gap.omittedAnnotations = c.annotations, c.omittedAnnotations
+		}
+	}
+	return result, nil
+}
+
+// attachExceptions records the exclusions falling inside each network in its
+// except list. Networks wholly covered by an exclusion are dropped.
+func attachExceptions(cidrs, exclusions []*CIDR) []*CIDR {
+	var result []*CIDR
+	for _, c := range cidrs {
+		inside, covered := overlappingExclusions(c, exclusions)
+		if covered {
+			continue
+		}
+		c.except = inside
+		result = append(result, c)
+	}
+	return result
+}
+
+// overlappingExclusions returns the exclusions strictly inside c, in order,
+// and whether any exclusion covers c entirely.
+func overlappingExclusions(c *CIDR, exclusions []*CIDR) (inside []*CIDR, covered bool) {
+	for _, e := range exclusions {
+		if e.Contains(c) {
+			return nil, true
+		}
+		if c.Contains(e) {
+			inside = append(inside, e)
+		}
+	}
+	return inside, false
+}
@@ -0,0 +1,144 @@
+package aggregate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExcludeNetworks(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []string
+		exclusions []string
+		want       []string
+	}{
+		{
+			name:       "No exclusions",
+			input:      []string{"10.0.0.0/8"},
+			exclusions: []string{},
+			want:       []string{"10.0.0.0/8"},
+		},
+		{
+			name:       "Hole in the middle",
+			input:      []string{"192.168.0.0/22"},
+			exclusions: []string{"192.168.1.0/24"},
+			want:       []string{"192.168.0.0/24", "192.168.2.0/23"},
+		},
+		{
+			name:       "Hole at the start",
+			input:      []string{"192.168.0.0/23"},
+			exclusions: []string{"192.168.0.0/24"},
+			want:       []string{"192.168.1.0/24"},
+		},
+		{
+			name:       "Hole at the end",
+			input:      []string{"192.168.0.0/23"},
+			exclusions: []string{"192.168.1.128/25"},
+			want:       []string{"192.168.0.0/24", "192.168.1.0/25"},
+		},
+		{
+			name:       "Several holes",
+			input:      []string{"10.0.0.0/29"},
+			exclusions: []string{"10.0.0.1/32", "10.0.0.6/32"},
+			want:       []string{"10.0.0.0/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.7/32"},
+		},
+		{
+			name:       "Network wholly excluded",
+			input:      []string{"10.1.0.0/16", "172.16.0.0/12"},
+			exclusions: []string{"10.0.0.0/8"},
+			want:       []string{"172.16.0.0/12"},
+		},
+		{
+			name:       "IPv6 hole",
+			input:      []string{"2001:db8::/31"},
+			exclusions: []string{"2001:db9::/32"},
+			want:       []string{"2001:db8::/32"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cidrs, exclusions []*CIDR
+			for _, s := range tt.input {
+				c, _ := parseCIDR(s)
+				cidrs = append(cidrs, c)
+			}
+			for _, s := range tt.exclusions {
+				c, _ := parseCIDR(s)
+				exclusions = append(exclusions, c)
+			}
+
+			got, err := excludeNetworks(cidrs, exclusions)
+			if err != nil {
+				t.Fatalf("excludeNetworks() unexpected error: %v", err)
+			}
+
+			var gotStrs []string
+			for _, c := range got {
+				gotStrs = append(gotStrs, c.String())
+			}
+			if strings.Join(gotStrs, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("excludeNetworks() = %v, want %v", gotStrs, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttachExceptions(t *testing.T) {
+	var cidrs, exclusions []*CIDR
+	for _, s := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.1.0/24"} {
+		c, _ := parseCIDR(s)
+		cidrs = append(cidrs, c)
+	}
+	for _, s := range []string{"10.1.0.0/16", "10.2.0.0/16", "192.168.0.0/16"} {
+		c, _ := parseCIDR(s)
+		exclusions = append(exclusions, c)
+	}
+
+	got := attachExceptions(cidrs, exclusions)
+
+	if len(got) != 2 {
+		t.Fatalf("attachExceptions() returned %d networks, want 2", len(got))
+	}
+	if len(got[0].except) != 2 || got[0].except[0].String() != "10.1.0.0/16" || got[0].except[1].String() != "10.2.0.0/16" {
+		t.Errorf("attachExceptions()[0].except = %v, want [10.1.0.0/16 10.2.0.0/16]", got[0].except)
+	}
+	if got[1].String() != "172.16.0.0/12" || len(got[1].except) != 0 {
+		t.Errorf("attachExceptions()[1] = %v except %v, want 172.16.0.0/12 without exceptions", got[1], got[1].except)
+	}
+}
+
+func TestRunWithExcludeMissingFile(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/8\n")
+	var output, errOutput bytes.Buffer
+
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	if err := Run(input, &output, &errOutput, Options{Format: formatNetworkPolicy, Exclude: missing}); err == nil {
+		t.Error("Run() expected error for missing exclude file, got nil")
+	}
+	if !strings.Contains(errOutput.String(), "exclude file") {
+		t.Errorf("Run() stderr = %q, want exclude file error", errOutput.String())
+	}
+}
+
+func TestRunWithExcludeAndCSVInput(t *testing.T) {
+	exclude := filepath.Join(t.TempDir(), "exclude.txt")
+	if err := os.WriteFile(exclude, []byte("192.168.1.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.NewReader("name,network\noffice,192.168.0.0/23\n")
+	var output, errOutput bytes.Buffer
+
+	opts := Options{Format: formatNetworkPolicy, InputFormat: inputCSV, Column: "network", Exclude: exclude}
+	if err := Run(input, &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if want := "        cidr: \"192.168.0.0/23\"\n        except:\n        - \"192.168.1.0/24\"\n"; !strings.HasSuffix(output.String(), want) {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
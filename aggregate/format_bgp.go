@@ -0,0 +1,113 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeExaBGP writes ExaBGP API announcements, one route per line,
+// for piping into an ExaBGP process.
+func writeExaBGP(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintln(w, exaBGPUpdate("announce", c, opts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGoBGP writes gobgp CLI commands adding each route to the global RIB.
+func writeGoBGP(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintln(w, goBGPUpdate("add", c, opts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exaBGPUpdate formats an ExaBGP announce or withdraw command.
+// Routes use "next-hop self" unless a next-hop is configured for the family.
+func exaBGPUpdate(verb string, c *CIDR, opts Options) string {
+	nextHop := opts.NextHopFor(c)
+	if nextHop == "" {
+		nextHop = "self"
+	}
+	line := fmt.Sprintf("%s route %s next-hop %s", verb, c, nextHop)
+	if opts.Community != "" {
+		line += fmt.Sprintf(" community [%s]", opts.Community)
+	}
+	return line
+}
+
+// goBGPUpdate formats a gobgp global rib add or del command.
+// Deletions only need the prefix, so path attributes are left off.
+func goBGPUpdate(verb string, c *CIDR, opts Options) string {
+	line := fmt.Sprintf("gobgp global rib %s -a %s %s", verb, familyName(c), c)
+	if verb == "del" {
+		return line
+	}
+	if nextHop := opts.NextHopFor(c); nextHop != "" {
+		line += " nexthop " + nextHop
+	}
+	if opts.Community != "" {
+		line += " community " + opts.Community
+	}
+	return line
+}
+
+// runDiff compares the processed networks against the list in opts.Diff,
+// processed the same way, and writes only the changes: announcements for
+// new networks, then withdrawals for networks that disappeared, so a
+// replacement is announced before the network it covers is withdrawn.
+func runDiff(output, errOutput io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	oldIPv4, oldIPv6, err := readNetworksFile(opts.Diff, "diff", errOutput, opts)
+	if err != nil {
+		return err
+	}
+	withdrawn4, announced4 := diffNetworks(oldIPv4, ipv4)
+	withdrawn6, announced6 := diffNetworks(oldIPv6, ipv6)
+
+	for _, changes := range []struct {
+		exaVerb, goVerb string
+		cidrs           [][]*CIDR
+	}{
+		{exaVerb: "announce", goVerb: "add", cidrs: [][]*CIDR{announced4, announced6}},
+		{exaVerb: "withdraw", goVerb: "del", cidrs: [][]*CIDR{withdrawn4, withdrawn6}},
+	} {
+		for _, cidrs := range changes.cidrs {
+			for _, c := range cidrs {
+				line := exaBGPUpdate(changes.exaVerb, c, opts)
+				if opts.Format == formatGoBGP {
+					line = goBGPUpdate(changes.goVerb, c, opts)
+				}
+				if _, err := fmt.Fprintln(output, line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// diffNetworks returns the networks only in old (withdrawn) and only in
+// current (announced), each in their original order.
+func diffNetworks(old, current []*CIDR) (withdrawn, announced []*CIDR) {
+	inOld := make(map[string]bool, len(old))
+	for _, c := range old {
+		inOld[c.String()] = true
+	}
+	inCurrent := make(map[string]bool, len(current))
+	for _, c := range current {
+		inCurrent[c.String()] = true
+		if !inOld[c.String()] {
+			announced = append(announced, c)
+		}
+	}
+	for _, c := range old {
+		if !inCurrent[c.String()] {
+			withdrawn = append(withdrawn, c)
+		}
+	}
+	return withdrawn, announced
+}
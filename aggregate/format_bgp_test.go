@@ -0,0 +1,197 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWithBGPFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "ExaBGP announcements",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatExaBGP},
+			wantOutput: "announce route 192.168.1.0/24 next-hop self\n" +
+				"announce route 2001:db8::/32 next-hop self\n",
+		},
+		{
+			name:  "ExaBGP with next-hop and community",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatExaBGP, NextHop4: "192.0.2.1", Community: "65535:666"},
+			wantOutput: "announce route 192.168.1.0/24 next-hop 192.0.2.1 community [65535:666]\n" +
+				"announce route 2001:db8::/32 next-hop self community [65535:666]\n",
+		},
+		{
+			name:  "GoBGP commands",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatGoBGP, NextHop6: "2001:db8:ffff::1", Community: "65535:666"},
+			wantOutput: "gobgp global rib add -a ipv4 192.168.1.0/24 community 65535:666\n" +
+				"gobgp global rib add -a ipv6 2001:db8::/32 nexthop 2001:db8:ffff::1 community 65535:666\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithDiff(t *testing.T) {
+	previous := filepath.Join(t.TempDir(), "previous.txt")
+	if err := os.WriteFile(previous, []byte("10.0.0.0/25\n10.0.0.128/25\n10.0.5.0/24\n2001:db8::/32\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		format     string
+		wantOutput string
+	}{
+		{
+			name:   "ExaBGP deltas",
+			input:  "10.0.0.0/24\n10.0.9.0/24\n2001:db8::/32\n",
+			format: formatExaBGP,
+			wantOutput: "announce route 10.0.9.0/24 next-hop self\n" +
+				"withdraw route 10.0.5.0/24 next-hop self\n",
+		},
+		{
+			name:   "Replacement announced before withdrawal",
+			input:  "10.0.0.0/24\n10.0.4.0/23\n2001:db8::/32\n",
+			format: formatExaBGP,
+			wantOutput: "announce route 10.0.4.0/23 next-hop self\n" +
+				"withdraw route 10.0.5.0/24 next-hop self\n",
+		},
+		{
+			name:       "Unchanged list",
+			input:      "10.0.0.0/24\n10.0.5.0/24\n2001:db8::/32\n",
+			format:     formatExaBGP,
+			wantOutput: "",
+		},
+		{
+			name:   "Empty list withdraws everything",
+			input:  "",
+			format: formatExaBGP,
+			wantOutput: "withdraw route 10.0.0.0/24 next-hop self\n" +
+				"withdraw route 10.0.5.0/24 next-hop self\n" +
+				"withdraw route 2001:db8::/32 next-hop self\n",
+		},
+		{
+			name:   "GoBGP deltas",
+			input:  "10.0.0.0/24\n2001:db8::/32\n2001:dba::/32\n",
+			format: formatGoBGP,
+			wantOutput: "gobgp global rib add -a ipv6 2001:dba::/32\n" +
+				"gobgp global rib del -a ipv4 10.0.5.0/24\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			err := Run(input, &output, &errOutput, Options{Format: tt.format, Diff: previous})
+			if err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithDiffMissingFile(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	err := Run(input, &output, &errOutput, Options{Format: formatExaBGP, Diff: missing})
+
+	if err == nil {
+		t.Error("Run() expected error for missing diff file, got nil")
+	}
+	if !strings.Contains(errOutput.String(), "diff file") {
+		t.Errorf("Run() stderr = %q, want diff file error", errOutput.String())
+	}
+}
+
+func TestDiffNetworks(t *testing.T) {
+	tests := []struct {
+		name          string
+		old, current  []string
+		wantWithdrawn string
+		wantAnnounced string
+	}{
+		{name: "No previous list", current: []string{"10.0.0.0/8"}, wantAnnounced: "10.0.0.0/8"},
+		{name: "Everything withdrawn", old: []string{"10.0.0.0/8"}, wantWithdrawn: "10.0.0.0/8"},
+		{name: "Unchanged", old: []string{"10.0.0.0/8"}, current: []string{"10.0.0.0/8"}},
+		{
+			name:          "Aggregation change replaces the prefix",
+			old:           []string{"10.0.0.0/24", "10.0.1.0/24"},
+			current:       []string{"10.0.0.0/23"},
+			wantWithdrawn: "10.0.0.0/24 10.0.1.0/24",
+			wantAnnounced: "10.0.0.0/23",
+		},
+	}
+
+	join := func(cidrs []*CIDR) string {
+		var parts []string
+		for _, c := range cidrs {
+			parts = append(parts, c.String())
+		}
+		return strings.Join(parts, " ")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withdrawn, announced := diffNetworks(mustParseCIDRs(t, tt.old...), mustParseCIDRs(t, tt.current...))
+			if join(withdrawn) != tt.wantWithdrawn || join(announced) != tt.wantAnnounced {
+				t.Errorf("diffNetworks() = %q, %q, want %q, %q", join(withdrawn), join(announced), tt.wantWithdrawn, tt.wantAnnounced)
+			}
+		})
+	}
+}
+
+func TestBGPUpdates(t *testing.T) {
+	c := mustParseCIDRs(t, "192.0.2.0/24")[0]
+	opts := Options{NextHop4: "192.0.2.1", Community: "65535:666"}
+
+	if got, want := exaBGPUpdate("withdraw", c, opts), "withdraw route 192.0.2.0/24 next-hop 192.0.2.1 community [65535:666]"; got != want {
+		t.Errorf("exaBGPUpdate() = %q, want %q", got, want)
+	}
+	if got, want := goBGPUpdate("del", c, opts), "gobgp global rib del -a ipv4 192.0.2.0/24"; got != want {
+		t.Errorf("goBGPUpdate() = %q, want %q", got, want)
+	}
+
+	for name, write := range map[string]func(io.Writer, []*CIDR, Options) error{
+		"writeExaBGP": writeExaBGP,
+		"writeGoBGP":  writeGoBGP,
+	} {
+		if err := write(failingWriter{}, []*CIDR{c}, opts); !errors.Is(err, errWriteFailed) {
+			t.Errorf("%s() error = %v, want %v", name, err, errWriteFailed)
+		}
+	}
+}
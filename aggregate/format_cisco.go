@@ -0,0 +1,116 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+)
+
+// Cisco numbers prefix-list entries in steps of five by default,
+// leaving room to insert entries by hand later.
+const (
+	ciscoSeqStart = 5
+	ciscoSeqStep  = 5
+)
+
+// NX-OS numbers access-list entries in steps of ten by default.
+const (
+	nxosSeqStart = 10
+	nxosSeqStep  = 10
+)
+
+// writeCiscoPrefixList writes an IOS/NX-OS ip (or ipv6) prefix-list.
+// The le/ge bounds are only added to entries they are valid for,
+// i.e. longer than the entry's own prefix length and within its family.
+// Sequence numbers continue from earlier chunks, since an entry with a
+// sequence number already in the list replaces it.
+func writeCiscoPrefixList(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for i, c := range cidrs {
+		seq := ciscoSeqStart + (opts.chunkStart+i)*ciscoSeqStep
+		line := fmt.Sprintf("%s prefix-list %s seq %d %s %s",
+			ciscoFamily(c), opts.ListName(), seq, opts.RuleAction(), c)
+		if opts.GE > c.ones && opts.GE <= c.bits {
+			line += fmt.Sprintf(" ge %d", opts.GE)
+		}
+		if opts.LE > c.ones && opts.LE <= c.bits && opts.LE >= opts.GE {
+			line += fmt.Sprintf(" le %d", opts.LE)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCiscoACL writes an IOS named extended access list matching the
+// networks as source addresses. IPv4 entries use wildcard masks; IPv6
+// entries use the ipv6 access-list variant with prefix notation.
+func writeCiscoACL(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	var err error
+	if cidrs[0].bits == 32 {
+		_, err = fmt.Fprintf(w, "ip access-list extended %s\n", opts.ListName())
+	} else {
+		_, err = fmt.Fprintf(w, "ipv6 access-list %s\n", opts.ListName())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, " %s %s %s any\n", opts.RuleAction(), ciscoFamily(c), ciscoACLSource(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeNXOSACL writes an NX-OS access list matching the networks as source
+// addresses. NX-OS has no extended keyword and takes prefix notation in
+// both families; entries are numbered, continuing from earlier chunks.
+func writeNXOSACL(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "%s access-list %s\n", ciscoFamily(cidrs[0]), opts.ListName()); err != nil {
+		return err
+	}
+	for i, c := range cidrs {
+		seq := nxosSeqStart + (opts.chunkStart+i)*nxosSeqStep
+		source := c.String()
+		if c.ones == 0 {
+			source = "any"
+		}
+		if _, err := fmt.Fprintf(w, "  %d %s %s %s any\n", seq, opts.RuleAction(), ciscoFamily(c), source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ciscoFamily returns the command and protocol keyword for the network's
+// address family.
+func ciscoFamily(c *CIDR) string {
+	if c.bits == 32 {
+		return "ip"
+	}
+	return "ipv6"
+}
+
+// ciscoACLSource formats a network as an ACL address operand,
+// using the host and any shorthands where they apply.
+func ciscoACLSource(c *CIDR) string {
+	switch {
+	case c.ones == 0:
+		return "any"
+	case c.ones == c.bits:
+		return "host " + c.ip.String()
+	case c.bits == 32:
+		return fmt.Sprintf("%s %s", c.ip, c.Wildcard())
+	default:
+		return c.String()
+	}
+}
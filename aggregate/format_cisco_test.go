@@ -0,0 +1,208 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteCiscoPrefixList(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		opts  Options
+		want  string
+	}{
+		{name: "Empty family", cidrs: nil, want: ""},
+		{
+			name:  "Later chunk continues the sequence",
+			cidrs: []string{"10.0.0.0/8", "192.168.1.0/24"},
+			opts:  Options{chunk: 2, chunkStart: 2},
+			want: "ip prefix-list aggregated seq 15 permit 10.0.0.0/8\n" +
+				"ip prefix-list aggregated seq 20 permit 192.168.1.0/24\n",
+		},
+		{
+			name:  "Bounds beyond the family are dropped",
+			cidrs: []string{"10.0.0.0/8"},
+			opts:  Options{GE: 33, LE: 48},
+			want:  "ip prefix-list aggregated seq 5 permit 10.0.0.0/8\n",
+		},
+		{
+			name:  "Bounds not longer than the entry are dropped",
+			cidrs: []string{"192.168.0.0/16"},
+			opts:  Options{GE: 8, LE: 16},
+			want:  "ip prefix-list aggregated seq 5 permit 192.168.0.0/16\n",
+		},
+		{
+			name:  "IPv6 bounds",
+			cidrs: []string{"2001:db8::/32"},
+			opts:  Options{LE: 48},
+			want:  "ipv6 prefix-list aggregated seq 5 permit 2001:db8::/32 le 48\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeCiscoPrefixList(&output, mustParseCIDRs(t, tt.cidrs...), tt.opts); err != nil {
+				t.Fatalf("writeCiscoPrefixList() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeCiscoPrefixList() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	if err := writeCiscoPrefixList(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeCiscoPrefixList() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestWriteNXOSACL(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		opts  Options
+		want  string
+	}{
+		{name: "Empty family", cidrs: nil, want: ""},
+		{
+			name:  "Prefix notation and default route",
+			cidrs: []string{"0.0.0.0/0", "10.0.0.1/32"},
+			opts:  Options{Name: "BLOCK", Action: "deny"},
+			want:  "ip access-list BLOCK\n  10 deny ip any any\n  20 deny ip 10.0.0.1/32 any\n",
+		},
+		{
+			name:  "Later chunk continues the sequence",
+			cidrs: []string{"2001:db8::/32"},
+			opts:  Options{chunk: 2, chunkStart: 3},
+			want:  "ipv6 access-list aggregated\n  40 permit ipv6 2001:db8::/32 any\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeNXOSACL(&output, mustParseCIDRs(t, tt.cidrs...), tt.opts); err != nil {
+				t.Fatalf("writeNXOSACL() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeNXOSACL() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	if err := writeNXOSACL(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeNXOSACL() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestWriteCiscoACL(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeCiscoACL(&output, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writeCiscoACL() of an empty family = %q, %v, want no output", output.String(), err)
+	}
+
+	if err := writeCiscoACL(&output, mustParseCIDRs(t, "::/0", "2001:db8::1/128"), Options{}); err != nil {
+		t.Fatalf("writeCiscoACL() unexpected error: %v", err)
+	}
+	want := "ipv6 access-list aggregated\n permit ipv6 any any\n permit ipv6 host 2001:db8::1 any\n"
+	if output.String() != want {
+		t.Errorf("writeCiscoACL() = %q, want %q", output.String(), want)
+	}
+
+	if err := writeCiscoACL(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeCiscoACL() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestRunWithChunkedCiscoPrefixList(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	if err := Run(input, &output, &errOutput, Options{Format: formatCiscoPrefixList, ChunkSize: 2}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	want := "! ipv4 chunk 1/2 (2 entries)\n" +
+		"ip prefix-list aggregated seq 5 permit 10.0.0.0/24\n" +
+		"ip prefix-list aggregated seq 10 permit 10.0.2.0/24\n" +
+		"! ipv4 chunk 2/2 (1 entries)\n" +
+		"ip prefix-list aggregated seq 15 permit 10.0.4.0/24\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
+
+func TestRunWithCiscoFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "Prefix-list defaults",
+			input: "192.168.1.0/24\n10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatCiscoPrefixList},
+			wantOutput: "ip prefix-list aggregated seq 5 permit 10.0.0.0/8\n" +
+				"ip prefix-list aggregated seq 10 permit 192.168.1.0/24\n" +
+				"ipv6 prefix-list aggregated seq 5 permit 2001:db8::/32\n",
+		},
+		{
+			name:       "Prefix-list name and action",
+			input:      "192.168.1.0/24\n",
+			opts:       Options{Format: formatCiscoPrefixList, Name: "BOGONS", Action: "deny"},
+			wantOutput: "ip prefix-list BOGONS seq 5 deny 192.168.1.0/24\n",
+		},
+		{
+			name:  "Prefix-list ge and le",
+			input: "10.0.0.0/8\n192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatCiscoPrefixList, GE: 16, LE: 24},
+			wantOutput: "ip prefix-list aggregated seq 5 permit 10.0.0.0/8 ge 16 le 24\n" +
+				"ip prefix-list aggregated seq 10 permit 192.168.1.0/24\n" +
+				"ipv6 prefix-list aggregated seq 5 permit 2001:db8::/32\n",
+		},
+		{
+			name:  "ACL wildcard masks and host",
+			input: "192.168.1.0/24\n10.0.0.1\n",
+			opts:  Options{Format: formatCiscoACL, Name: "BLOCK", Action: "deny"},
+			wantOutput: "ip access-list extended BLOCK\n" +
+				" deny ip host 10.0.0.1 any\n" +
+				" deny ip 192.168.1.0 0.0.0.255 any\n",
+		},
+		{
+			name:  "ACL IPv6 variant",
+			input: "192.168.1.0/24\n2001:db8::/32\n2001:db9::1\n",
+			opts:  Options{Format: formatCiscoACL},
+			wantOutput: "ip access-list extended aggregated\n" +
+				" permit ip 192.168.1.0 0.0.0.255 any\n" +
+				"ipv6 access-list aggregated\n" +
+				" permit ipv6 2001:db8::/32 any\n" +
+				" permit ipv6 host 2001:db9::1 any\n",
+		},
+		{
+			name:       "ACL default route",
+			input:      "0.0.0.0/0\n",
+			opts:       Options{Format: formatCiscoACL},
+			wantOutput: "ip access-list extended aggregated\n permit ip any any\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
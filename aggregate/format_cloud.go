@@ -0,0 +1,281 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Provider limits on the number of address entries per rule or object.
+// AWS counts each range as a rule with a default quota of 60 per security
+// group and accepts at most 100 prefix list entries per API request; GCP
+// allows 5000 source ranges per firewall rule and Azure 4000 address
+// prefixes per security rule.
+const (
+	awsSecurityGroupLimit = 60
+	awsPrefixListLimit    = 100
+	gcpFirewallLimit      = 5000
+	azureNSGLimit         = 4000
+)
+
+// Provider limits on the length of rule and entry descriptions, in
+// characters.
+const (
+	awsDescriptionLimit   = 255
+	gcpDescriptionLimit   = 2048
+	azureDescriptionLimit = 140
+)
+
+// awsDescriptionChars matches the characters AWS accepts in security group
+// rule and prefix list entry descriptions.
+var awsDescriptionChars = regexp.MustCompile(`^[a-zA-Z0-9. _\-:/()#,@\[\]+=&;{}!$*]$`)
+
+// gcpRuleName matches the resource names GCP accepts for firewall rules.
+var gcpRuleName = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// awsSecurityGroupIngress is the input of "aws ec2
+// authorize-security-group-ingress --cli-input-json" for one security group.
+type awsSecurityGroupIngress struct {
+	GroupName     string            `json:"GroupName"`
+	IPPermissions []awsIPPermission `json:"IpPermissions"`
+}
+
+// awsIPPermission is an EC2 IpPermission.
+type awsIPPermission struct {
+	IPProtocol string         `json:"IpProtocol"`
+	IPRanges   []awsIPRange   `json:"IpRanges,omitempty"`
+	IPv6Ranges []awsIPv6Range `json:"Ipv6Ranges,omitempty"`
+}
+
+type awsIPRange struct {
+	CidrIP      string `json:"CidrIp"`
+	Description string `json:"Description,omitempty"`
+}
+
+type awsIPv6Range struct {
+	CidrIPv6    string `json:"CidrIpv6"`
+	Description string `json:"Description,omitempty"`
+}
+
+// awsPrefixList is the input of "aws ec2 create-managed-prefix-list
+// --cli-input-json".
+type awsPrefixList struct {
+	PrefixListName string               `json:"PrefixListName"`
+	AddressFamily  string               `json:"AddressFamily"`
+	MaxEntries     int                  `json:"MaxEntries"`
+	Entries        []awsPrefixListEntry `json:"Entries"`
+}
+
+type awsPrefixListEntry struct {
+	Cidr        string `json:"Cidr"`
+	Description string `json:"Description,omitempty"`
+}
+
+// gcpFirewallRule is a Compute Engine firewall resource. GCP rules cannot
+// mix address families, so each rule holds one family.
+type gcpFirewallRule struct {
+	Name         string        `json:"name"`
+	Description  string        `json:"description,omitempty"`
+	Direction    string        `json:"direction"`
+	Priority     int           `json:"priority"`
+	SourceRanges []string      `json:"sourceRanges"`
+	Allowed      []gcpProtocol `json:"allowed,omitempty"`
+	Denied       []gcpProtocol `json:"denied,omitempty"`
+}
+
+type gcpProtocol struct {
+	IPProtocol string `json:"IPProtocol"`
+}
+
+// azureSecurityRule is a network security group rule as it appears in the
+// securityRules array of an ARM template.
+type azureSecurityRule struct {
+	Name       string                      `json:"name"`
+	Properties azureSecurityRuleProperties `json:"properties"`
+}
+
+type azureSecurityRuleProperties struct {
+	Description              string   `json:"description,omitempty"`
+	Priority                 int      `json:"priority"`
+	Direction                string   `json:"direction"`
+	Access                   string   `json:"access"`
+	Protocol                 string   `json:"protocol"`
+	SourceAddressPrefixes    []string `json:"sourceAddressPrefixes"`
+	SourcePortRange          string   `json:"sourcePortRange"`
+	DestinationAddressPrefix string   `json:"destinationAddressPrefix"`
+	DestinationPortRange     string   `json:"destinationPortRange"`
+}
+
+// writeAWSSecurityGroup writes a JSON array of ingress payloads allowing all
+// protocols, one per security group. Each group holds one family and at
+// most one security group's worth of ranges, so the ranges never exceed the
+// rule quota of the group they are added to.
+func writeAWSSecurityGroup(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	description := awsDescription(opts.Comment)
+	var payloads []awsSecurityGroupIngress
+	for _, group := range cloudGroups(ipv4, ipv6, opts.entryLimit(awsSecurityGroupLimit)) {
+		permission := awsIPPermission{IPProtocol: "-1"}
+		for _, c := range group.cidrs {
+			if c.bits == 32 {
+				permission.IPRanges = append(permission.IPRanges, awsIPRange{CidrIP: c.String(), Description: description})
+			} else {
+				permission.IPv6Ranges = append(permission.IPv6Ranges, awsIPv6Range{CidrIPv6: c.String(), Description: description})
+			}
+		}
+		payloads = append(payloads, awsSecurityGroupIngress{
+			GroupName:     group.name(opts),
+			IPPermissions: []awsIPPermission{permission},
+		})
+	}
+	return writeJSON(w, payloads)
+}
+
+// writeAWSPrefixList writes a JSON array of managed prefix list definitions,
+// one per family and batch of entries.
+func writeAWSPrefixList(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	description := awsDescription(opts.Comment)
+	var lists []awsPrefixList
+	for _, group := range cloudGroups(ipv4, ipv6, opts.entryLimit(awsPrefixListLimit)) {
+		family := "IPv4"
+		if group.cidrs[0].bits == 128 {
+			family = "IPv6"
+		}
+		list := awsPrefixList{
+			PrefixListName: group.name(opts),
+			AddressFamily:  family,
+			MaxEntries:     len(group.cidrs),
+		}
+		for _, c := range group.cidrs {
+			list.Entries = append(list.Entries, awsPrefixListEntry{Cidr: c.String(), Description: description})
+		}
+		lists = append(lists, list)
+	}
+	return writeJSON(w, lists)
+}
+
+// writeGCPFirewall writes a JSON array of ingress firewall rules matching
+// all protocols from the networks, allowed or denied per -action.
+func writeGCPFirewall(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	var rules []gcpFirewallRule
+	for _, group := range cloudGroups(ipv4, ipv6, opts.entryLimit(gcpFirewallLimit)) {
+		name := group.name(opts)
+		if !gcpRuleName.MatchString(name) {
+			return fmt.Errorf("invalid GCP firewall rule name %q: names are at most 63 lowercase letters, digits and hyphens", name)
+		}
+		rule := gcpFirewallRule{
+			Name:         name,
+			Description:  truncate(opts.Comment, gcpDescriptionLimit),
+			Direction:    "INGRESS",
+			Priority:     1000,
+			SourceRanges: cidrStrings(group.cidrs),
+		}
+		all := []gcpProtocol{{IPProtocol: "all"}}
+		if opts.RuleAction() == "deny" {
+			rule.Denied = all
+		} else {
+			rule.Allowed = all
+		}
+		rules = append(rules, rule)
+	}
+	return writeJSON(w, rules)
+}
+
+// writeAzureNSG writes a JSON array of inbound network security group rules
+// with unique priorities starting at 100.
+func writeAzureNSG(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	access := "Allow"
+	if opts.RuleAction() == "deny" {
+		access = "Deny"
+	}
+
+	var rules []azureSecurityRule
+	for i, group := range cloudGroups(ipv4, ipv6, opts.entryLimit(azureNSGLimit)) {
+		rules = append(rules, azureSecurityRule{
+			Name: group.name(opts),
+			Properties: azureSecurityRuleProperties{
+				Description:              truncate(opts.Comment, azureDescriptionLimit),
+				Priority:                 100 + i*10,
+				Direction:                "Inbound",
+				Access:                   access,
+				Protocol:                 "*",
+				SourceAddressPrefixes:    cidrStrings(group.cidrs),
+				SourcePortRange:          "*",
+				DestinationAddressPrefix: "*",
+				DestinationPortRange:     "*",
+			},
+		})
+	}
+	return writeJSON(w, rules)
+}
+
+// cloudGroup is a batch of networks from one family that fits in a
+// single provider rule or object.
+type cloudGroup struct {
+	family string
+	n      int
+	cidrs  []*CIDR
+}
+
+// name returns a unique rule name such as "aggregated-ipv4-1".
+func (g cloudGroup) name(opts Options) string {
+	return fmt.Sprintf("%s-%s-%d", opts.ListName(), g.family, g.n)
+}
+
+// cloudGroups splits each family into batches of at most limit networks.
+func cloudGroups(ipv4, ipv6 []*CIDR, limit int) []cloudGroup {
+	var groups []cloudGroup
+	for _, family := range []struct {
+		name  string
+		cidrs []*CIDR
+	}{{"ipv4", ipv4}, {"ipv6", ipv6}} {
+		for i, chunk := range chunkNetworks(family.cidrs, limit, false) {
+			groups = append(groups, cloudGroup{family: family.name, n: i + 1, cidrs: chunk})
+		}
+	}
+	return groups
+}
+
+// awsDescription drops the characters AWS rejects from a description and
+// truncates it to the length AWS accepts.
+func awsDescription(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if awsDescriptionChars.MatchString(string(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return truncate(b.String(), awsDescriptionLimit)
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// cidrStrings returns the networks in CIDR notation.
+func cidrStrings(cidrs []*CIDR) []string {
+	out := make([]string, len(cidrs))
+	for i, c := range cidrs {
+		out[i] = c.String()
+	}
+	return out
+}
+
+// writeJSON writes v as indented JSON. A nil slice is written as [].
+func writeJSON(w io.Writer, v any) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if string(out) == "null" {
+		out = []byte("[]")
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
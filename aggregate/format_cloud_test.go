@@ -0,0 +1,205 @@
+package aggregate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// runCloudFormat runs input through Run and decodes the JSON output into v.
+func runCloudFormat(t *testing.T, input string, opts Options, v any) {
+	t.Helper()
+	var output, errOutput bytes.Buffer
+
+	if err := Run(strings.NewReader(input), &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if err := json.Unmarshal(output.Bytes(), v); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, output.String())
+	}
+}
+
+func TestRunWithAWSSecurityGroup(t *testing.T) {
+	var got []awsSecurityGroupIngress
+	runCloudFormat(t, "192.168.1.0/24\n10.0.0.1\n2001:db8::/32\n", Options{Format: formatAWSSecurityGroup, Comment: "office"}, &got)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d payloads, want 2 (one per family)", len(got))
+	}
+	if got[0].GroupName != "aggregated-ipv4-1" || got[1].GroupName != "aggregated-ipv6-1" {
+		t.Errorf("group names = %q, %q", got[0].GroupName, got[1].GroupName)
+	}
+	ipv4, ipv6 := got[0].IPPermissions, got[1].IPPermissions
+	if len(ipv4) != 1 || ipv4[0].IPProtocol != "-1" || len(ipv4[0].IPRanges) != 2 || len(ipv4[0].IPv6Ranges) != 0 {
+		t.Fatalf("IPv4 permissions = %+v", ipv4)
+	}
+	if ipv4[0].IPRanges[1].CidrIP != "192.168.1.0/24" || ipv4[0].IPRanges[1].Description != "office" {
+		t.Errorf("IPv4 range = %+v", ipv4[0].IPRanges[1])
+	}
+	if len(ipv6) != 1 || len(ipv6[0].IPv6Ranges) != 1 || ipv6[0].IPv6Ranges[0].CidrIPv6 != "2001:db8::/32" {
+		t.Errorf("IPv6 permissions = %+v", ipv6)
+	}
+}
+
+func TestRunWithAWSSecurityGroupSplitsAtLimit(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < awsSecurityGroupLimit+5; i++ {
+		fmt.Fprintf(&input, "10.0.%d.0/24\n", i*2)
+	}
+
+	var got []awsSecurityGroupIngress
+	runCloudFormat(t, input.String(), Options{Format: formatAWSSecurityGroup, Name: "blocked"}, &got)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d payloads, want 2 (one per security group)", len(got))
+	}
+	for i, want := range []struct {
+		group  string
+		ranges int
+	}{{"blocked-ipv4-1", awsSecurityGroupLimit}, {"blocked-ipv4-2", 5}} {
+		if got[i].GroupName != want.group || len(got[i].IPPermissions) != 1 || len(got[i].IPPermissions[0].IPRanges) != want.ranges {
+			t.Errorf("payload %d = %s with %+v, want %s with %d ranges", i, got[i].GroupName, got[i].IPPermissions, want.group, want.ranges)
+		}
+	}
+}
+
+func TestWriteAWSSecurityGroupEmpty(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeAWSSecurityGroup(&output, nil, nil, Options{}); err != nil {
+		t.Fatalf("writeAWSSecurityGroup() unexpected error: %v", err)
+	}
+	if output.String() != "[]\n" {
+		t.Errorf("writeAWSSecurityGroup() = %q, want an empty array", output.String())
+	}
+}
+
+func TestRunWithAWSPrefixList(t *testing.T) {
+	var got []awsPrefixList
+	runCloudFormat(t, "192.168.1.0/24\n10.0.0.0/8\n2001:db8::/32\n", Options{Format: formatAWSPrefixList, Name: "office", RuleEntries: 1}, &got)
+
+	want := []struct {
+		name, family, cidr string
+	}{
+		{"office-ipv4-1", "IPv4", "10.0.0.0/8"},
+		{"office-ipv4-2", "IPv4", "192.168.1.0/24"},
+		{"office-ipv6-1", "IPv6", "2001:db8::/32"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d prefix lists, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].PrefixListName != w.name || got[i].AddressFamily != w.family || got[i].MaxEntries != 1 ||
+			len(got[i].Entries) != 1 || got[i].Entries[0].Cidr != w.cidr {
+			t.Errorf("prefix list %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestRunWithGCPFirewall(t *testing.T) {
+	var got []gcpFirewallRule
+	runCloudFormat(t, "192.168.1.0/24\n2001:db8::/32\n", Options{Format: formatGCPFirewall, Action: "deny"}, &got)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d rules, want 2 (families cannot be mixed)", len(got))
+	}
+	if got[0].Name != "aggregated-ipv4-1" || got[0].Direction != "INGRESS" ||
+		strings.Join(got[0].SourceRanges, ",") != "192.168.1.0/24" {
+		t.Errorf("IPv4 rule = %+v", got[0])
+	}
+	if len(got[0].Denied) != 1 || got[0].Denied[0].IPProtocol != "all" || got[0].Allowed != nil {
+		t.Errorf("IPv4 rule protocols = allowed %+v denied %+v, want denied all", got[0].Allowed, got[0].Denied)
+	}
+	if got[1].Name != "aggregated-ipv6-1" || strings.Join(got[1].SourceRanges, ",") != "2001:db8::/32" {
+		t.Errorf("IPv6 rule = %+v", got[1])
+	}
+}
+
+func TestRunWithAzureNSG(t *testing.T) {
+	var got []azureSecurityRule
+	runCloudFormat(t, "192.168.1.0/24\n10.0.0.0/8\n2001:db8::/32\n", Options{Format: formatAzureNSG, RuleEntries: 1}, &got)
+
+	if len(got) != 3 {
+		t.Fatalf("got %d rules, want 3", len(got))
+	}
+	for i, wantPriority := range []int{100, 110, 120} {
+		if got[i].Properties.Priority != wantPriority {
+			t.Errorf("rule %d priority = %d, want %d", i, got[i].Properties.Priority, wantPriority)
+		}
+		if got[i].Properties.Access != "Allow" || got[i].Properties.Direction != "Inbound" {
+			t.Errorf("rule %d = %+v, want inbound allow", i, got[i].Properties)
+		}
+	}
+	if strings.Join(got[2].Properties.SourceAddressPrefixes, ",") != "2001:db8::/32" {
+		t.Errorf("IPv6 rule prefixes = %v", got[2].Properties.SourceAddressPrefixes)
+	}
+}
+
+func TestWriteGCPFirewallRuleNames(t *testing.T) {
+	var input []*CIDR
+	for i := 0; i < 10; i++ {
+		input = append(input, mustParseCIDRs(t, fmt.Sprintf("10.%d.0.0/16", i))...)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Default name", opts: Options{}},
+		{name: "Longest name", opts: Options{Name: strings.Repeat("a", 56), RuleEntries: 5}},
+		{name: "Rule number pushes name over 63 characters", opts: Options{Name: strings.Repeat("a", 56), RuleEntries: 1}, wantErr: true},
+		{name: "Underscore", opts: Options{Name: "office_net"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := writeGCPFirewall(&output, input, nil, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeGCPFirewall() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && output.Len() != 0 {
+				t.Errorf("writeGCPFirewall() wrote %q before failing", output.String())
+			}
+		})
+	}
+}
+
+func TestAWSDescription(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "Allowed characters kept", in: "SBL123 (spam) #1: a/b, c@d [x]+=&;{}!$*", want: "SBL123 (spam) #1: a/b, c@d [x]+=&;{}!$*"},
+		{name: "Rejected characters dropped", in: `Spamhaus <DROP> "edrop" café`, want: "Spamhaus DROP edrop caf"},
+		{name: "Truncated to limit", in: strings.Repeat("x", awsDescriptionLimit+10), want: strings.Repeat("x", awsDescriptionLimit)},
+		{name: "Empty", in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := awsDescription(tt.in); got != tt.want {
+				t.Errorf("awsDescription(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAzureNSGTruncatesDescription(t *testing.T) {
+	var output bytes.Buffer
+	opts := Options{Comment: strings.Repeat("é", azureDescriptionLimit+1)}
+	if err := writeAzureNSG(&output, mustParseCIDRs(t, "10.0.0.0/8"), nil, opts); err != nil {
+		t.Fatalf("writeAzureNSG() unexpected error: %v", err)
+	}
+
+	var got []azureSecurityRule
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if want := strings.Repeat("é", azureDescriptionLimit); got[0].Properties.Description != want {
+		t.Errorf("description = %q, want %d characters", got[0].Properties.Description, azureDescriptionLimit)
+	}
+}
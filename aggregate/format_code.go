@@ -0,0 +1,174 @@
+package aggregate
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// defaultGoPackage names the package of generated Go source when -package
+// is not given.
+const defaultGoPackage = "main"
+
+// writeGoSource writes a Go source file declaring the networks of both
+// families as a []netip.Prefix variable named after the list.
+func writeGoSource(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	name := goIdentifier(opts.ListName())
+
+	var b bytes.Buffer
+	writeGoHeader(&b, opts)
+	fmt.Fprintf(&b, "// %s holds the aggregated networks.\nvar %s = []netip.Prefix{\n", name, name)
+	for _, c := range append(append([]*CIDR{}, ipv4...), ipv6...) {
+		fmt.Fprintf(&b, "\tnetip.MustParsePrefix(%q),\n", c)
+	}
+	b.WriteString("}\n")
+	return writeGoFormatted(w, b.Bytes())
+}
+
+// writeGoRanges writes a Go source file declaring the first and last
+// addresses of every network in two parallel slices sorted for binary
+// search, e.g. with slices.BinarySearchFunc and netip.Addr.Compare.
+// IPv4 addresses sort before IPv6, so both families share the slices.
+func writeGoRanges(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	name := goIdentifier(opts.ListName())
+	cidrs := append(append([]*CIDR{}, ipv4...), ipv6...)
+
+	var b bytes.Buffer
+	writeGoHeader(&b, opts)
+	fmt.Fprintf(&b, "// %sFirst and %sLast hold the first and last addresses of each\n", name, name)
+	b.WriteString("// aggregated network, sorted for binary search.\nvar (\n")
+	for _, bound := range []string{"First", "Last"} {
+		fmt.Fprintf(&b, "\t%s%s = []netip.Addr{\n", name, bound)
+		for _, c := range cidrs {
+			first, last := c.bounds()
+			addr := first
+			if bound == "Last" {
+				addr = last
+			}
+			fmt.Fprintf(&b, "\t\tnetip.MustParseAddr(%q), // %s\n", bigIntToIP(addr, c.bits), c)
+		}
+		b.WriteString("\t}\n")
+	}
+	b.WriteString(")\n")
+	return writeGoFormatted(w, b.Bytes())
+}
+
+// writeGoHeader writes the generated-code notice, package clause and
+// netip import shared by the Go formats.
+func writeGoHeader(b *bytes.Buffer, opts Options) {
+	pkg := opts.GoPackage
+	if pkg == "" {
+		pkg = defaultGoPackage
+	}
+	fmt.Fprintf(b, "// Code generated by aggregate-cidr; DO NOT EDIT.\n\npackage %s\n\nimport \"net/netip\"\n\n", pkg)
+}
+
+// writeGoFormatted runs generated Go source through gofmt before writing it.
+func writeGoFormatted(w io.Writer, src []byte) error {
+	out, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("formatting generated Go source: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// goIdentifier converts a list name such as "threat-intel" into a Go
+// identifier such as "threatIntel". Keywords get a trailing underscore.
+func goIdentifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := 1; i < len(words); i++ {
+		r := []rune(words[i])
+		words[i] = string(unicode.ToUpper(r[0])) + string(r[1:])
+	}
+	id := strings.Join(words, "")
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "_" + id
+	}
+	if token.IsKeyword(id) {
+		id += "_"
+	}
+	return id
+}
+
+// writeCHeader writes a C header declaring each family's networks and masks
+// as packed arrays: IPv4 as host-order uint32_t values and IPv6 as 16-byte
+// arrays in network order, with a NAME_V4_COUNT/NAME_V6_COUNT macro giving
+// their length.
+func writeCHeader(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	name := cIdentifier(opts.ListName())
+	macro := strings.ToUpper(name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "/* Generated by aggregate-cidr; do not edit. */\n\n#ifndef %s_H\n#define %s_H\n\n#include <stdint.h>\n", macro, macro)
+
+	arrays := []struct {
+		suffix string
+		value  func(*CIDR) []byte
+	}{
+		{"network", func(c *CIDR) []byte { return c.ip }},
+		{"mask", func(c *CIDR) []byte { return c.Netmask() }},
+	}
+
+	// C has no zero-length arrays, so an empty family is declared as a null
+	// pointer that code indexing up to the count can use the same way
+	fmt.Fprintf(&b, "\n#define %s_V4_COUNT %d\n", macro, len(ipv4))
+	for _, array := range arrays {
+		if len(ipv4) == 0 {
+			fmt.Fprintf(&b, "static const uint32_t *const %s_v4_%s = 0;\n", name, array.suffix)
+			continue
+		}
+		fmt.Fprintf(&b, "static const uint32_t %s_v4_%s[%s_V4_COUNT] = {\n", name, array.suffix, macro)
+		for _, c := range ipv4 {
+			fmt.Fprintf(&b, "\t0x%08x, /* %s */\n", array.value(c), c)
+		}
+		b.WriteString("};\n")
+	}
+
+	fmt.Fprintf(&b, "\n#define %s_V6_COUNT %d\n", macro, len(ipv6))
+	for _, array := range arrays {
+		if len(ipv6) == 0 {
+			fmt.Fprintf(&b, "static const uint8_t (*const %s_v6_%s)[16] = 0;\n", name, array.suffix)
+			continue
+		}
+		fmt.Fprintf(&b, "static const uint8_t %s_v6_%s[%s_V6_COUNT][16] = {\n", name, array.suffix, macro)
+		for _, c := range ipv6 {
+			fmt.Fprintf(&b, "\t{%s}, /* %s */\n", cBytes(array.value(c)), c)
+		}
+		b.WriteString("};\n")
+	}
+
+	fmt.Fprintf(&b, "\n#endif /* %s_H */\n", macro)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cIdentifier converts a list name into a C identifier by replacing every
+// character other than letters, digits and underscores.
+func cIdentifier(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "_" + id
+	}
+	return id
+}
+
+// cBytes formats b as a comma-separated list of hex byte literals.
+func cBytes(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("0x%02x", v)
+	}
+	return strings.Join(parts, ", ")
+}
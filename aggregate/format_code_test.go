@@ -0,0 +1,120 @@
+package aggregate
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGoIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"aggregated", "aggregated"},
+		{"threat-intel", "threatIntel"},
+		{"Office_ranges v2", "OfficeRangesV2"},
+		{"2024-list", "_2024List"},
+		{"---", "_"},
+		{"var", "var_"},
+		{"Type", "Type"},
+	}
+
+	for _, tt := range tests {
+		if got := goIdentifier(tt.name); got != tt.want {
+			t.Errorf("goIdentifier(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunWithCodeFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "Go prefixes",
+			input: "10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatGoSource, Name: "allow-list", GoPackage: "acl"},
+			wantOutput: "// Code generated by aggregate-cidr; DO NOT EDIT.\n\npackage acl\n\nimport \"net/netip\"\n\n" +
+				"// allowList holds the aggregated networks.\nvar allowList = []netip.Prefix{\n" +
+				"\tnetip.MustParsePrefix(\"10.0.0.0/8\"),\n" +
+				"\tnetip.MustParsePrefix(\"2001:db8::/32\"),\n}\n",
+		},
+		{
+			name:  "C header",
+			input: "10.0.0.0/8\n192.0.2.1\n",
+			opts:  Options{Format: formatCHeader, Name: "allow-list"},
+			wantOutput: "/* Generated by aggregate-cidr; do not edit. */\n\n" +
+				"#ifndef ALLOW_LIST_H\n#define ALLOW_LIST_H\n\n#include <stdint.h>\n\n" +
+				"#define ALLOW_LIST_V4_COUNT 2\n" +
+				"static const uint32_t allow_list_v4_network[ALLOW_LIST_V4_COUNT] = {\n" +
+				"\t0x0a000000, /* 10.0.0.0/8 */\n\t0xc0000201, /* 192.0.2.1/32 */\n};\n" +
+				"static const uint32_t allow_list_v4_mask[ALLOW_LIST_V4_COUNT] = {\n" +
+				"\t0xff000000, /* 10.0.0.0/8 */\n\t0xffffffff, /* 192.0.2.1/32 */\n};\n\n" +
+				"#define ALLOW_LIST_V6_COUNT 0\n" +
+				"static const uint8_t (*const allow_list_v6_network)[16] = 0;\n" +
+				"static const uint8_t (*const allow_list_v6_mask)[16] = 0;\n\n" +
+				"#endif /* ALLOW_LIST_H */\n",
+		},
+		{
+			name:  "C header IPv6",
+			input: "2001:db8::/32\n",
+			opts:  Options{Format: formatCHeader},
+			wantOutput: "/* Generated by aggregate-cidr; do not edit. */\n\n" +
+				"#ifndef AGGREGATED_H\n#define AGGREGATED_H\n\n#include <stdint.h>\n\n" +
+				"#define AGGREGATED_V4_COUNT 0\n" +
+				"static const uint32_t *const aggregated_v4_network = 0;\n" +
+				"static const uint32_t *const aggregated_v4_mask = 0;\n\n" +
+				"#define AGGREGATED_V6_COUNT 1\n" +
+				"static const uint8_t aggregated_v6_network[AGGREGATED_V6_COUNT][16] = {\n" +
+				"\t{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, /* 2001:db8::/32 */\n};\n" +
+				"static const uint8_t aggregated_v6_mask[AGGREGATED_V6_COUNT][16] = {\n" +
+				"\t{0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, /* 2001:db8::/32 */\n};\n\n" +
+				"#endif /* AGGREGATED_H */\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithGoRanges(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/8\n2001:db8::/32\n")
+	var output, errOutput bytes.Buffer
+
+	if err := Run(input, &output, &errOutput, Options{Format: formatGoRanges}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "ranges.go", output.Bytes(), 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, output.String())
+	}
+	for _, want := range []string{
+		"aggregatedFirst = []netip.Addr{",
+		"netip.MustParseAddr(\"10.0.0.0\"),   // 10.0.0.0/8",
+		"aggregatedLast = []netip.Addr{",
+		"netip.MustParseAddr(\"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\"), // 2001:db8::/32",
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("generated source missing %q:\n%s", want, output.String())
+		}
+	}
+}
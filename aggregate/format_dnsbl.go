@@ -0,0 +1,92 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default record data for listed networks in DNSBL formats.
+const (
+	defaultRBLDNSDValue = "127.0.0.2"
+	defaultRPZValue     = "CNAME ."
+)
+
+// writeRBLDNSD writes an rbldnsd dataset for one family, introduced by a
+// $DATASET line so both families can share a combined zone file: ip4set for
+// IPv4 and ip6trie for IPv6. Listed networks return the configured A record
+// and the comment as TXT; without a comment, entries return their own
+// annotations.
+func writeRBLDNSD(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	dataset := "ip4set"
+	if cidrs[0].bits == 128 {
+		dataset = "ip6trie"
+	}
+	value := opts.MapValue(defaultRBLDNSDValue)
+	if _, err := fmt.Fprintf(w, "$DATASET %s:%s @\n:%s:%s\n", dataset, opts.ListName(), value, opts.Comment); err != nil {
+		return err
+	}
+
+	for _, c := range cidrs {
+		var err error
+		if opts.Comment == "" && len(c.annotations) > 0 {
+			_, err = fmt.Fprintf(w, "%s :%s:%s\n", c, value, opts.EntryComment(c))
+		} else {
+			_, err = fmt.Fprintln(w, c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRPZ writes DNS Response Policy Zone rpz-ip triggers, one per network,
+// for inclusion in a policy zone. Each trigger answers with the configured
+// record data (NXDOMAIN by default) and carries the entry's comment as a
+// zone file comment.
+func writeRPZ(w io.Writer, cidrs []*CIDR, opts Options) error {
+	value := opts.MapValue(defaultRPZValue)
+	for _, c := range cidrs {
+		line := rpzOwner(c) + " " + value
+		if comment := opts.EntryComment(c); comment != "" {
+			line += " ; " + comment
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rpzOwner returns the rpz-ip owner name for c: the prefix length followed
+// by the address labels in reverse order. IPv6 groups are written without
+// leading zeros and the run of zero groups that :: would compress is "zz".
+func rpzOwner(c *CIDR) string {
+	var labels []string
+	if c.bits == 32 {
+		labels = strings.Split(c.ip.String(), ".")
+	} else {
+		before, after, compressed := strings.Cut(c.ip.String(), "::")
+		labels = rpzGroups(before)
+		if compressed {
+			labels = append(append(labels, "zz"), rpzGroups(after)...)
+		}
+	}
+	slices.Reverse(labels)
+	return strconv.Itoa(c.ones) + "." + strings.Join(labels, ".") + ".rpz-ip"
+}
+
+// rpzGroups splits part of an IPv6 address into its colon-separated groups.
+func rpzGroups(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ":")
+}
@@ -0,0 +1,115 @@
+package aggregate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRPZOwner(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want string
+	}{
+		{"10.0.0.0/8", "8.0.0.0.10.rpz-ip"},
+		{"192.0.2.1/32", "32.1.2.0.192.rpz-ip"},
+		{"2001:db8::/32", "32.zz.db8.2001.rpz-ip"},
+		{"2001:db8:0:1::/64", "64.zz.1.0.db8.2001.rpz-ip"},
+		{"2001:db8::1/128", "128.1.zz.db8.2001.rpz-ip"},
+		{"2001:db8:1:2:3:4:5:6/128", "128.6.5.4.3.2.1.db8.2001.rpz-ip"},
+		{"::/0", "0.zz.rpz-ip"},
+	}
+
+	for _, tt := range tests {
+		c, _ := parseCIDR(tt.cidr)
+		if got := rpzOwner(c); got != tt.want {
+			t.Errorf("rpzOwner(%q) = %q, want %q", tt.cidr, got, tt.want)
+		}
+	}
+}
+
+func TestRunWithDNSBLFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "rbldnsd with annotations",
+			input: "10.0.0.0/8 ; scanner\n192.0.2.1\n2001:db8::/32\n",
+			opts:  Options{Format: formatRBLDNSD, Name: "dnsbl"},
+			wantOutput: "$DATASET ip4set:dnsbl @\n:127.0.0.2:\n10.0.0.0/8 :127.0.0.2:scanner\n192.0.2.1/32\n" +
+				"$DATASET ip6trie:dnsbl @\n:127.0.0.2:\n2001:db8::/32\n",
+		},
+		{
+			name:       "rbldnsd with value and TXT",
+			input:      "10.0.0.0/8 ; scanner\n",
+			opts:       Options{Format: formatRBLDNSD, Value: "127.0.0.4", Comment: "Listed, see https://example.com/$"},
+			wantOutput: "$DATASET ip4set:aggregated @\n:127.0.0.4:Listed, see https://example.com/$\n10.0.0.0/8\n",
+		},
+		{
+			name:       "RPZ NXDOMAIN",
+			input:      "10.0.0.0/8 ; scanner\n2001:db8::/32\n",
+			opts:       Options{Format: formatRPZ},
+			wantOutput: "8.0.0.0.10.rpz-ip CNAME . ; scanner\n32.zz.db8.2001.rpz-ip CNAME .\n",
+		},
+		{
+			name:       "RPZ local data",
+			input:      "192.0.2.0/24\n",
+			opts:       Options{Format: formatRPZ, Value: "A 127.0.0.2"},
+			wantOutput: "24.0.2.0.192.rpz-ip A 127.0.0.2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteRBLDNSD(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeRBLDNSD(&output, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writeRBLDNSD() without networks = %q, %v, want no output", output.String(), err)
+	}
+
+	ipv6 := mustParseCIDRs(t, "2001:db8::/32", "2001:db8:1::1")
+	ipv6[0].annotations = []string{"scanner", "spam"}
+	if err := writeRBLDNSD(&output, ipv6, Options{Name: "dnsbl", Comment: "listed"}); err != nil {
+		t.Fatalf("writeRBLDNSD() unexpected error: %v", err)
+	}
+
+	// -comment is the dataset's default TXT, so entries do not repeat it
+	want := "$DATASET ip6trie:dnsbl @\n:127.0.0.2:listed\n2001:db8::/32\n2001:db8:1::1/128\n"
+	if output.String() != want {
+		t.Errorf("writeRBLDNSD() = %q, want %q", output.String(), want)
+	}
+}
+
+func TestRunWithChunkedRPZ(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	opts := Options{Format: formatRPZ, ChunkSize: 2}
+	if err := Run(input, &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	want := "; ipv4 chunk 1/2 (2 entries)\n24.0.0.0.10.rpz-ip CNAME .\n24.0.2.0.10.rpz-ip CNAME .\n" +
+		"; ipv4 chunk 2/2 (1 entries)\n24.0.4.0.10.rpz-ip CNAME .\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
@@ -0,0 +1,152 @@
+package aggregate
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Suricata IP reputation defaults used when -category and -score are not given.
+const (
+	defaultIPRepCategory = 1
+	defaultIPRepScore    = 127
+)
+
+// writeZeekIntel writes a Zeek Intel framework file holding both families.
+// Single addresses are Intel::ADDR indicators and networks Intel::SUBNET;
+// each carries the feed name and the entry's comment as metadata.
+func writeZeekIntel(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintln(w, "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc"); err != nil {
+		return err
+	}
+	source := zeekField(opts.FeedSource())
+	for _, c := range append(append([]*CIDR{}, ipv4...), ipv6...) {
+		indicator, indicatorType := c.String(), "Intel::SUBNET"
+		if c.ones == c.bits {
+			indicator, indicatorType = c.ip.String(), "Intel::ADDR"
+		}
+		desc := zeekField(opts.EntryComment(c))
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", indicator, indicatorType, source, desc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zeekField makes s safe for a tab-separated Zeek input field. Zeek reads
+// "-" as an unset field, so empty values are written that way.
+func zeekField(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// writeSuricataIPRep writes a Suricata IP reputation file of
+// "network,category,score" lines.
+func writeSuricataIPRep(w io.Writer, cidrs []*CIDR, opts Options) error {
+	category, score := opts.Category, opts.Score
+	if category == 0 {
+		category = defaultIPRepCategory
+	}
+	if score == 0 {
+		score = defaultIPRepScore
+	}
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "%s,%d,%d\n", c, category, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stixBundle is a STIX 2.1 bundle.
+type stixBundle struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Objects []any  `json:"objects"`
+}
+
+// stixIdentity is the STIX 2.1 identity of the feed producing the indicators.
+type stixIdentity struct {
+	Type          string `json:"type"`
+	SpecVersion   string `json:"spec_version"`
+	ID            string `json:"id"`
+	Created       string `json:"created"`
+	Modified      string `json:"modified"`
+	Name          string `json:"name"`
+	IdentityClass string `json:"identity_class"`
+}
+
+// stixIndicator is a STIX 2.1 indicator matching one network.
+type stixIndicator struct {
+	Type         string `json:"type"`
+	SpecVersion  string `json:"spec_version"`
+	ID           string `json:"id"`
+	CreatedByRef string `json:"created_by_ref"`
+	Created      string `json:"created"`
+	Modified     string `json:"modified"`
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Pattern      string `json:"pattern"`
+	PatternType  string `json:"pattern_type"`
+	ValidFrom    string `json:"valid_from"`
+}
+
+// writeSTIX writes a STIX 2.1 bundle holding an identity for the feed and
+// one indicator per network, described by the entry's comment.
+func writeSTIX(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	identity := stixIdentity{
+		Type:          "identity",
+		SpecVersion:   "2.1",
+		ID:            stixID("identity"),
+		Created:       now,
+		Modified:      now,
+		Name:          opts.FeedSource(),
+		IdentityClass: "system",
+	}
+
+	bundle := stixBundle{Type: "bundle", ID: stixID("bundle"), Objects: []any{identity}}
+	for _, c := range append(append([]*CIDR{}, ipv4...), ipv6...) {
+		object := "ipv4-addr"
+		if c.bits == 128 {
+			object = "ipv6-addr"
+		}
+		bundle.Objects = append(bundle.Objects, stixIndicator{
+			Type:         "indicator",
+			SpecVersion:  "2.1",
+			ID:           stixID("indicator"),
+			CreatedByRef: identity.ID,
+			Created:      now,
+			Modified:     now,
+			Name:         c.String(),
+			Description:  opts.EntryComment(c),
+			Pattern:      fmt.Sprintf("[%s:value = '%s']", object, c),
+			PatternType:  "stix",
+			ValidFrom:    now,
+		})
+	}
+	return writeJSON(w, bundle)
+}
+
+// stixID returns a STIX identifier for an object of the given type, built
+// from a random (version 4) UUID.
+func stixID(objectType string) string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%s--%x-%x-%x-%x-%x", objectType, u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
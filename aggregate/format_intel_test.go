@@ -0,0 +1,165 @@
+package aggregate
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRunWithIntelFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "Zeek Intel",
+			input: "10.0.0.1 ; scanner\n192.168.0.0/24\n2001:db8::/32 # tor\texit\n",
+			opts:  Options{Format: formatZeekIntel, Source: "spamhaus"},
+			wantOutput: "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc\n" +
+				"10.0.0.1\tIntel::ADDR\tspamhaus\tscanner\n" +
+				"192.168.0.0/24\tIntel::SUBNET\tspamhaus\t-\n" +
+				"2001:db8::/32\tIntel::SUBNET\tspamhaus\ttor exit\n",
+		},
+		{
+			name:  "Zeek Intel with comment",
+			input: "10.0.0.0/8 ; scanner\n",
+			opts:  Options{Format: formatZeekIntel, Comment: "blocklist"},
+			wantOutput: "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc\n" +
+				"10.0.0.0/8\tIntel::SUBNET\taggregate-cidr\tblocklist\n",
+		},
+		{
+			name:       "Zeek Intel empty",
+			input:      "",
+			opts:       Options{Format: formatZeekIntel},
+			wantOutput: "",
+		},
+		{
+			name:       "Suricata iprep defaults",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatSuricataIPRep},
+			wantOutput: "10.0.0.0/8,1,127\n2001:db8::/32,1,127\n",
+		},
+		{
+			name:       "Suricata iprep category and score",
+			input:      "10.0.0.1\n",
+			opts:       Options{Format: formatSuricataIPRep, Category: 4, Score: 50},
+			wantOutput: "10.0.0.1/32,4,50\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithSTIX(t *testing.T) {
+	var got struct {
+		Type    string `json:"type"`
+		ID      string `json:"id"`
+		Objects []struct {
+			Type         string `json:"type"`
+			ID           string `json:"id"`
+			Name         string `json:"name"`
+			CreatedByRef string `json:"created_by_ref"`
+			Description  string `json:"description"`
+			Pattern      string `json:"pattern"`
+		} `json:"objects"`
+	}
+	runCloudFormat(t, "10.0.0.0/24 ; botnet C2\n10.0.1.0/24 ; scanner\n2001:db8::/32\n", Options{Format: formatSTIX, Source: "soc"}, &got)
+
+	uuid := `[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`
+	if got.Type != "bundle" || !regexp.MustCompile(`^bundle--`+uuid).MatchString(got.ID) {
+		t.Errorf("bundle = %q %q", got.Type, got.ID)
+	}
+	if len(got.Objects) != 3 {
+		t.Fatalf("got %d objects, want identity and 2 indicators", len(got.Objects))
+	}
+
+	identity := got.Objects[0]
+	if identity.Type != "identity" || identity.Name != "soc" {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	tests := []struct {
+		pattern     string
+		description string
+	}{
+		{"[ipv4-addr:value = '10.0.0.0/23']", "botnet C2; scanner"},
+		{"[ipv6-addr:value = '2001:db8::/32']", ""},
+	}
+	for i, tt := range tests {
+		indicator := got.Objects[i+1]
+		if indicator.Type != "indicator" || !regexp.MustCompile(`^indicator--`+uuid).MatchString(indicator.ID) {
+			t.Errorf("indicator %d = %q %q", i, indicator.Type, indicator.ID)
+		}
+		if indicator.CreatedByRef != identity.ID {
+			t.Errorf("indicator %d created_by_ref = %q, want %q", i, indicator.CreatedByRef, identity.ID)
+		}
+		if indicator.Pattern != tt.pattern || indicator.Description != tt.description {
+			t.Errorf("indicator %d = %q %q, want %q %q", i, indicator.Pattern, indicator.Description, tt.pattern, tt.description)
+		}
+	}
+}
+
+func TestWriteZeekIntel(t *testing.T) {
+	ipv4 := mustParseCIDRs(t, "10.0.0.0/8")
+	ipv6 := mustParseCIDRs(t, "2001:db8::1")
+	ipv4[0].annotations = []string{"scanner\tbrute  force", "spam"}
+
+	var output bytes.Buffer
+	if err := writeZeekIntel(&output, ipv4, ipv6, Options{Source: "SOC feed"}); err != nil {
+		t.Fatalf("writeZeekIntel() unexpected error: %v", err)
+	}
+
+	want := "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc\n" +
+		"10.0.0.0/8\tIntel::SUBNET\tSOC feed\tscanner brute force; spam\n" +
+		"2001:db8::1\tIntel::ADDR\tSOC feed\t-\n"
+	if output.String() != want {
+		t.Errorf("writeZeekIntel() = %q, want %q", output.String(), want)
+	}
+
+	output.Reset()
+	if err := writeZeekIntel(&output, nil, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writeZeekIntel() without networks = %q, %v, want no output", output.String(), err)
+	}
+}
+
+func TestWriteSuricataIPRep(t *testing.T) {
+	cidrs := mustParseCIDRs(t, "10.0.0.0/8")
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{name: "Defaults", opts: Options{}, want: "10.0.0.0/8,1,127\n"},
+		{name: "Category and score", opts: Options{Category: 60, Score: 1}, want: "10.0.0.0/8,60,1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeSuricataIPRep(&output, cidrs, tt.opts); err != nil {
+				t.Fatalf("writeSuricataIPRep() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeSuricataIPRep() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+}
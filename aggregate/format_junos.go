@@ -0,0 +1,71 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeJunosPrefixList writes a Junos prefix-list in set-command form.
+// Junos prefix-lists may hold both families, so IPv4 and IPv6 entries
+// share one list and follow each other as in the default output.
+func writeJunosPrefixList(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "set policy-options prefix-list %s %s\n", opts.ListName(), c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJunosConfig writes the prefix-list as a structured configuration
+// stanza suitable for "load merge".
+func writeJunosConfig(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if len(ipv4) == 0 && len(ipv6) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "policy-options {\n    prefix-list %s {\n", opts.ListName()); err != nil {
+		return err
+	}
+	for _, cidrs := range [][]*CIDR{ipv4, ipv6} {
+		for _, c := range cidrs {
+			if _, err := fmt.Fprintf(w, "        %s;\n", c); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprint(w, "    }\n}\n")
+	return err
+}
+
+// writeJunosFilter writes a firewall filter skeleton for the network's
+// family (inet or inet6): one term matching the networks as source
+// addresses, followed by a default term taking the opposite action.
+func writeJunosFilter(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	filter := fmt.Sprintf("set firewall family %s filter %s", junosFamily(cidrs[0]), opts.ListName())
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "%s term %s from source-address %s\n", filter, opts.ListName(), c); err != nil {
+			return err
+		}
+	}
+
+	match, fallback := "accept", "discard"
+	if opts.RuleAction() == "deny" {
+		match, fallback = "discard", "accept"
+	}
+	_, err := fmt.Fprintf(w, "%s term %s then %s\n%s term default then %s\n",
+		filter, opts.ListName(), match, filter, fallback)
+	return err
+}
+
+// junosFamily returns the Junos protocol family for the network.
+func junosFamily(c *CIDR) string {
+	if c.bits == 32 {
+		return "inet"
+	}
+	return "inet6"
+}
@@ -0,0 +1,137 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunWithJunosFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "Prefix-list set form",
+			input: "192.168.1.0/24\n10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatJunosPrefixList, Name: "BOGONS"},
+			wantOutput: "set policy-options prefix-list BOGONS 10.0.0.0/8\n" +
+				"set policy-options prefix-list BOGONS 192.168.1.0/24\n" +
+				"set policy-options prefix-list BOGONS 2001:db8::/32\n",
+		},
+		{
+			name:  "Structured config",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatJunosConfig},
+			wantOutput: "policy-options {\n" +
+				"    prefix-list aggregated {\n" +
+				"        192.168.1.0/24;\n" +
+				"        2001:db8::/32;\n" +
+				"    }\n" +
+				"}\n",
+		},
+		{
+			name:       "Structured config empty",
+			input:      "# nothing\n",
+			opts:       Options{Format: formatJunosConfig},
+			wantOutput: "",
+		},
+		{
+			name:  "Filter deny",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatJunosFilter, Name: "BLOCK", Action: "deny"},
+			wantOutput: "set firewall family inet filter BLOCK term BLOCK from source-address 192.168.1.0/24\n" +
+				"set firewall family inet filter BLOCK term BLOCK then discard\n" +
+				"set firewall family inet filter BLOCK term default then accept\n" +
+				"set firewall family inet6 filter BLOCK term BLOCK from source-address 2001:db8::/32\n" +
+				"set firewall family inet6 filter BLOCK term BLOCK then discard\n" +
+				"set firewall family inet6 filter BLOCK term default then accept\n",
+		},
+		{
+			name:  "Filter permit",
+			input: "192.168.1.0/24\n",
+			opts:  Options{Format: formatJunosFilter},
+			wantOutput: "set firewall family inet filter aggregated term aggregated from source-address 192.168.1.0/24\n" +
+				"set firewall family inet filter aggregated term aggregated then accept\n" +
+				"set firewall family inet filter aggregated term default then discard\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteJunosConfig(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeJunosConfig(&output, nil, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writeJunosConfig() without networks = %q, %v, want no output", output.String(), err)
+	}
+
+	if err := writeJunosConfig(&output, nil, mustParseCIDRs(t, "2001:db8::/32"), Options{Name: "v6-only"}); err != nil {
+		t.Fatalf("writeJunosConfig() unexpected error: %v", err)
+	}
+	want := "policy-options {\n    prefix-list v6-only {\n        2001:db8::/32;\n    }\n}\n"
+	if output.String() != want {
+		t.Errorf("writeJunosConfig() = %q, want %q", output.String(), want)
+	}
+
+	if err := writeJunosConfig(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), nil, Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeJunosConfig() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestWriteJunosFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		opts  Options
+		want  string
+	}{
+		{name: "Empty family", cidrs: nil, want: ""},
+		{
+			name:  "Deny inverts the default term",
+			cidrs: []string{"2001:db8::/32"},
+			opts:  Options{Name: "block", Action: "deny"},
+			want: "set firewall family inet6 filter block term block from source-address 2001:db8::/32\n" +
+				"set firewall family inet6 filter block term block then discard\n" +
+				"set firewall family inet6 filter block term default then accept\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeJunosFilter(&output, mustParseCIDRs(t, tt.cidrs...), tt.opts); err != nil {
+				t.Fatalf("writeJunosFilter() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeJunosFilter() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	for _, write := range []func() error{
+		func() error { return writeJunosFilter(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), Options{}) },
+		func() error { return writeJunosPrefixList(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), Options{}) },
+	} {
+		if err := write(); !errors.Is(err, errWriteFailed) {
+			t.Errorf("write error = %v, want %v", err, errWriteFailed)
+		}
+	}
+}
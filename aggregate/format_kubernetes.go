@@ -0,0 +1,89 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeNetworkPolicy writes a Kubernetes NetworkPolicy selecting every pod in
+// the namespace. NetworkPolicies can only allow traffic, so with -action
+// permit each network becomes an egress ipBlock (carrying any -exclude
+// entries as except lists), while with -action deny egress is allowed to
+// everywhere except the networks.
+func writeNetworkPolicy(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	var peers strings.Builder
+	if opts.RuleAction() == "deny" {
+		// Both families are listed so the policy does not cut off egress
+		// for a family with nothing to block. A family denying /0 gets no
+		// block at all, as an except list must lie strictly inside its
+		// cidr.
+		for _, family := range []struct {
+			all   string
+			cidrs []*CIDR
+		}{{"0.0.0.0/0", ipv4}, {"::/0", ipv6}} {
+			if !coversAll(family.cidrs) {
+				writeIPBlock(&peers, family.all, family.cidrs)
+			}
+		}
+	} else {
+		for _, cidrs := range [][]*CIDR{ipv4, ipv6} {
+			for _, c := range cidrs {
+				writeIPBlock(&peers, c.String(), c.except)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nmetadata:\n")
+	fmt.Fprintf(&b, "  name: %q\n", opts.ListName())
+	b.WriteString("spec:\n  podSelector: {}\n  policyTypes:\n  - Egress\n")
+	if peers.Len() == 0 {
+		// A rule without peers would allow egress everywhere
+		b.WriteString("  egress: []\n")
+	} else {
+		b.WriteString("  egress:\n  - to:\n")
+		b.WriteString(peers.String())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// coversAll reports whether the networks include a /0.
+func coversAll(cidrs []*CIDR) bool {
+	for _, c := range cidrs {
+		if c.ones == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIPBlock appends a NetworkPolicy peer for cidr with its except list.
+func writeIPBlock(b *strings.Builder, cidr string, except []*CIDR) {
+	fmt.Fprintf(b, "    - ipBlock:\n        cidr: %q\n", cidr)
+	if len(except) == 0 {
+		return
+	}
+	b.WriteString("        except:\n")
+	for _, e := range except {
+		fmt.Fprintf(b, "        - %q\n", e.String())
+	}
+}
+
+// writeGlobalNetworkSet writes a Calico GlobalNetworkSet holding both
+// families, labelled so policies can select it by name.
+func writeGlobalNetworkSet(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	var b strings.Builder
+	b.WriteString("apiVersion: projectcalico.org/v3\nkind: GlobalNetworkSet\nmetadata:\n")
+	fmt.Fprintf(&b, "  name: %q\n  labels:\n    aggregate-cidr/name: %q\nspec:\n  nets:\n", opts.ListName(), opts.ListName())
+	for _, cidrs := range [][]*CIDR{ipv4, ipv6} {
+		for _, c := range cidrs {
+			fmt.Fprintf(&b, "  - %q\n", c.String())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
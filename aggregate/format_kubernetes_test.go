@@ -0,0 +1,89 @@
+package aggregate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWithKubernetesFormats(t *testing.T) {
+	exclude := filepath.Join(t.TempDir(), "exclude.txt")
+	if err := os.WriteFile(exclude, []byte("10.1.0.0/16\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	header := "apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nmetadata:\n  name: \"threat-intel\"\n" +
+		"spec:\n  podSelector: {}\n  policyTypes:\n  - Egress\n  egress:\n  - to:\n"
+
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "NetworkPolicy allow with except",
+			input: "10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatNetworkPolicy, Name: "threat-intel", Exclude: exclude},
+			wantOutput: header +
+				"    - ipBlock:\n        cidr: \"10.0.0.0/8\"\n        except:\n        - \"10.1.0.0/16\"\n" +
+				"    - ipBlock:\n        cidr: \"2001:db8::/32\"\n",
+		},
+		{
+			name:  "NetworkPolicy deny",
+			input: "10.0.0.0/8\n192.168.1.0/24\n",
+			opts:  Options{Format: formatNetworkPolicy, Name: "threat-intel", Action: "deny"},
+			wantOutput: header +
+				"    - ipBlock:\n        cidr: \"0.0.0.0/0\"\n        except:\n        - \"10.0.0.0/8\"\n        - \"192.168.1.0/24\"\n" +
+				"    - ipBlock:\n        cidr: \"::/0\"\n",
+		},
+		{
+			name:  "NetworkPolicy deny subtracts exclusions",
+			input: "10.0.0.0/15\n",
+			opts:  Options{Format: formatNetworkPolicy, Name: "threat-intel", Action: "deny", Exclude: exclude},
+			wantOutput: header +
+				"    - ipBlock:\n        cidr: \"0.0.0.0/0\"\n        except:\n        - \"10.0.0.0/16\"\n" +
+				"    - ipBlock:\n        cidr: \"::/0\"\n",
+		},
+		{
+			name:  "NetworkPolicy deny everywhere in one family",
+			input: "0.0.0.0/0\n2001:db8::/32\n",
+			opts:  Options{Format: formatNetworkPolicy, Name: "threat-intel", Action: "deny"},
+			wantOutput: header +
+				"    - ipBlock:\n        cidr: \"::/0\"\n        except:\n        - \"2001:db8::/32\"\n",
+		},
+		{
+			name:  "NetworkPolicy deny everywhere",
+			input: "0.0.0.0/0\n::/0\n",
+			opts:  Options{Format: formatNetworkPolicy, Name: "threat-intel", Action: "deny"},
+			wantOutput: "apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nmetadata:\n  name: \"threat-intel\"\n" +
+				"spec:\n  podSelector: {}\n  policyTypes:\n  - Egress\n  egress: []\n",
+		},
+		{
+			name:  "Calico GlobalNetworkSet",
+			input: "10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatGlobalNetworkSet, Name: "threat-intel"},
+			wantOutput: "apiVersion: projectcalico.org/v3\nkind: GlobalNetworkSet\nmetadata:\n" +
+				"  name: \"threat-intel\"\n  labels:\n    aggregate-cidr/name: \"threat-intel\"\n" +
+				"spec:\n  nets:\n  - \"10.0.0.0/8\"\n  - \"2001:db8::/32\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
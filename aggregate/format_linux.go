@@ -0,0 +1,69 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// defaultBPFValue is the LPM map value written when -value is not given:
+// a little-endian 32-bit 1.
+const defaultBPFValue = "01 00 00 00"
+
+// bpfHexBytes matches a map value given as space-separated hex bytes.
+var bpfHexBytes = regexp.MustCompile(`^[0-9a-fA-F]{2}( [0-9a-fA-F]{2})*$`)
+
+// bpfMapName matches the map names the kernel accepts: at most 15
+// characters (BPF_OBJ_NAME_LEN less the terminating NUL) of letters,
+// digits, underscores and dots.
+var bpfMapName = regexp.MustCompile(`^[a-zA-Z0-9_.]{1,15}$`)
+
+// writeIPBatch writes an "ip -batch" file adding a blackhole route per
+// network, or a route via the family's next-hop when one is configured.
+func writeIPBatch(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		route := "blackhole " + c.String()
+		if nextHop := opts.NextHopFor(c); nextHop != "" {
+			route = c.String() + " via " + nextHop
+		}
+		if _, err := fmt.Fprintf(w, "route add %s\n", route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBPFToolLPM writes a "bpftool batch file" of map updates for an LPM
+// trie named NAME_v4 or NAME_v6. Each key is the bpf_lpm_trie_key layout:
+// the prefix length as a little-endian 32-bit value followed by the address
+// bytes in network order.
+func writeBPFToolLPM(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	name := opts.ListName() + "_v4"
+	if cidrs[0].bits == 128 {
+		name = opts.ListName() + "_v6"
+	}
+	value := opts.MapValue(defaultBPFValue)
+
+	for _, c := range cidrs {
+		key := []byte{byte(c.ones), 0, 0, 0} //nolint:gosec // G115: ones is bounded [0, 128]
+		key = append(key, c.ip...)
+		if _, err := fmt.Fprintf(w, "map update name %s key hex %s value hex %s\n", name, hexBytes(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hexBytes formats b as space-separated hex bytes, as bpftool expects.
+func hexBytes(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,106 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunWithLinuxFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "ip batch blackhole",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatIPBatch},
+			wantOutput: "route add blackhole 10.0.0.0/8\nroute add blackhole 2001:db8::/32\n",
+		},
+		{
+			name:       "ip batch next-hop",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatIPBatch, NextHop4: "192.0.2.1"},
+			wantOutput: "route add 10.0.0.0/8 via 192.0.2.1\nroute add blackhole 2001:db8::/32\n",
+		},
+		{
+			name:  "bpftool LPM keys",
+			input: "10.0.0.0/8\n192.0.2.1\n2001:db8::/32\n",
+			opts:  Options{Format: formatBPFToolLPM, Name: "xdp_block"},
+			wantOutput: "map update name xdp_block_v4 key hex 08 00 00 00 0a 00 00 00 value hex 01 00 00 00\n" +
+				"map update name xdp_block_v4 key hex 20 00 00 00 c0 00 02 01 value hex 01 00 00 00\n" +
+				"map update name xdp_block_v6 key hex 20 00 00 00 20 01 0d b8 00 00 00 00 00 00 00 00 00 00 00 00 value hex 01 00 00 00\n",
+		},
+		{
+			name:       "bpftool LPM value",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatBPFToolLPM, Value: "02"},
+			wantOutput: "map update name aggregated_v4 key hex 08 00 00 00 0a 00 00 00 value hex 02\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteBPFToolLPM(t *testing.T) {
+	tests := []struct {
+		name       string
+		cidrs      []*CIDR
+		wantOutput string
+	}{
+		{
+			name:       "Default route",
+			cidrs:      mustParseCIDRs(t, "0.0.0.0/0"),
+			wantOutput: "map update name lpm_v4 key hex 00 00 00 00 00 00 00 00 value hex 01 00 00 00\n",
+		},
+		{
+			name:  "IPv6 host",
+			cidrs: mustParseCIDRs(t, "2001:db8::1"),
+			wantOutput: "map update name lpm_v6 key hex 80 00 00 00 20 01 0d b8 00 00 00 00 00 00 00 00 00 00 00 01" +
+				" value hex 01 00 00 00\n",
+		},
+		{
+			name:       "No networks",
+			wantOutput: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeBPFToolLPM(&output, tt.cidrs, Options{Name: "lpm"}); err != nil {
+				t.Fatalf("writeBPFToolLPM() unexpected error: %v", err)
+			}
+			if output.String() != tt.wantOutput {
+				t.Errorf("writeBPFToolLPM() = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteLinuxFormatsWriteError(t *testing.T) {
+	cidrs := mustParseCIDRs(t, "10.0.0.0/8")
+	if err := writeIPBatch(failingWriter{}, cidrs, Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeIPBatch() error = %v, want %v", err, errWriteFailed)
+	}
+	if err := writeBPFToolLPM(failingWriter{}, cidrs, Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeBPFToolLPM() error = %v, want %v", err, errWriteFailed)
+	}
+}
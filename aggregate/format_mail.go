@@ -0,0 +1,58 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+)
+
+// writePostfixCIDR writes a Postfix cidr: lookup table pairing each network
+// with an access action: OK for permit lists and REJECT for deny lists,
+// unless -value gives another action such as "554 5.7.1 Blocked".
+func writePostfixCIDR(w io.Writer, cidrs []*CIDR, opts Options) error {
+	action := "OK"
+	if opts.RuleAction() == "deny" {
+		action = "REJECT"
+	}
+	action = opts.MapValue(action)
+
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "%s %s\n", c, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRspamdMap writes an Rspamd radix map, one network per line with the
+// entry's comment after a "#".
+func writeRspamdMap(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		line := c.String()
+		if comment := opts.EntryComment(c); comment != "" {
+			line += " # " + comment
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEximIPLsearch writes an Exim iplsearch file keyed by network, with
+// the entry's comment as the lookup data. IPv6 keys are quoted so their
+// colons are not read as the key terminator.
+func writeEximIPLsearch(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		key := c.String()
+		if c.bits == 128 {
+			key = `"` + key + `"`
+		}
+		if comment := opts.EntryComment(c); comment != "" {
+			key += ": " + comment
+		}
+		if _, err := fmt.Fprintln(w, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
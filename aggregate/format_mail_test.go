@@ -0,0 +1,106 @@
+package aggregate
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunWithMailFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "Postfix permit",
+			input:      "192.168.0.0/24\n192.168.1.0/24\n2001:db8::/32\n",
+			opts:       Options{Format: formatPostfixCIDR},
+			wantOutput: "192.168.0.0/23 OK\n2001:db8::/32 OK\n",
+		},
+		{
+			name:       "Postfix deny",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatPostfixCIDR, Action: "deny"},
+			wantOutput: "10.0.0.0/8 REJECT\n",
+		},
+		{
+			name:       "Postfix custom action",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatPostfixCIDR, Action: "deny", Value: "554 5.7.1 Listed in local blocklist"},
+			wantOutput: "10.0.0.0/8 554 5.7.1 Listed in local blocklist\n",
+		},
+		{
+			name:       "Rspamd map",
+			input:      "10.0.0.0/8 ; scanner\n2001:db8::/32\n",
+			opts:       Options{Format: formatRspamdMap},
+			wantOutput: "10.0.0.0/8 # scanner\n2001:db8::/32\n",
+		},
+		{
+			name:       "Exim iplsearch",
+			input:      "10.0.0.0/8\n2001:db8::/32 ; spam source\n",
+			opts:       Options{Format: formatEximIPLsearch},
+			wantOutput: "10.0.0.0/8\n\"2001:db8::/32\": spam source\n",
+		},
+		{
+			name:       "Exim iplsearch with comment",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatEximIPLsearch, Comment: "blocked"},
+			wantOutput: "10.0.0.0/8: blocked\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteMailFormats(t *testing.T) {
+	annotated := func() []*CIDR {
+		cidrs := mustParseCIDRs(t, "2001:db8::/32", "2001:db8:1::1")
+		cidrs[0].annotations = []string{"scanner", "spam"}
+		return cidrs
+	}
+
+	tests := []struct {
+		name       string
+		write      func(io.Writer, []*CIDR, Options) error
+		cidrs      []*CIDR
+		opts       Options
+		wantOutput string
+	}{
+		{name: "Postfix deny", write: writePostfixCIDR, cidrs: mustParseCIDRs(t, "10.0.0.0/8"), opts: Options{Action: "deny"}, wantOutput: "10.0.0.0/8 REJECT\n"},
+		{name: "Postfix value overrides action", write: writePostfixCIDR, cidrs: mustParseCIDRs(t, "10.0.0.0/8"), opts: Options{Action: "deny", Value: "554 5.7.1 Blocked"}, wantOutput: "10.0.0.0/8 554 5.7.1 Blocked\n"},
+		{name: "Postfix no networks", write: writePostfixCIDR, wantOutput: ""},
+		{name: "Rspamd annotations", write: writeRspamdMap, cidrs: annotated(), wantOutput: "2001:db8::/32 # scanner; spam\n2001:db8:1::1/128\n"},
+		{name: "Rspamd comment replaces annotations", write: writeRspamdMap, cidrs: annotated(), opts: Options{Comment: "intel"}, wantOutput: "2001:db8::/32 # intel\n2001:db8:1::1/128 # intel\n"},
+		{name: "Exim quotes IPv6 keys", write: writeEximIPLsearch, cidrs: annotated(), wantOutput: "\"2001:db8::/32\": scanner; spam\n\"2001:db8:1::1/128\"\n"},
+		{name: "Exim IPv4 keys bare", write: writeEximIPLsearch, cidrs: mustParseCIDRs(t, "10.0.0.0/8"), wantOutput: "10.0.0.0/8\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := tt.write(&output, tt.cidrs, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// routerOSTimeout matches RouterOS durations such as 1d, 12h or 1w2d.
+var routerOSTimeout = regexp.MustCompile(`^([0-9]+[wdhms])+$`)
+
+// writeMikroTik writes a RouterOS script that recreates the named firewall
+// address-list for the network's family: existing entries of the list are
+// removed, then one entry is added per network. Only the first chunk
+// removes the list, so later chunks add to it rather than replacing it.
+func writeMikroTik(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	menu := "/ip firewall address-list"
+	if cidrs[0].bits == 128 {
+		menu = "/ipv6 firewall address-list"
+	}
+	list := routerOSQuote(opts.ListName())
+	if _, err := fmt.Fprintln(w, menu); err != nil {
+		return err
+	}
+	if opts.chunk <= 1 {
+		if _, err := fmt.Fprintf(w, "remove [find list=%s]\n", list); err != nil {
+			return err
+		}
+	}
+
+	var attrs string
+	if opts.Comment != "" {
+		attrs += " comment=" + routerOSQuote(opts.Comment)
+	}
+	if opts.Timeout != "" {
+		attrs += " timeout=" + opts.Timeout
+	}
+
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "add list=%s address=%s%s\n", list, c, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// routerOSQuote returns s as a double-quoted RouterOS string, escaping the
+// characters the script parser would otherwise interpret.
+func routerOSQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,148 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunWithMikroTikFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "Both families",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatMikroTik, Name: "blocklist"},
+			wantOutput: "/ip firewall address-list\n" +
+				"remove [find list=\"blocklist\"]\n" +
+				"add list=\"blocklist\" address=192.168.1.0/24\n" +
+				"/ipv6 firewall address-list\n" +
+				"remove [find list=\"blocklist\"]\n" +
+				"add list=\"blocklist\" address=2001:db8::/32\n",
+		},
+		{
+			name:  "Comment and timeout",
+			input: "192.168.1.0/24\n10.0.0.1\n",
+			opts:  Options{Format: formatMikroTik, Comment: "drop list", Timeout: "1d"},
+			wantOutput: "/ip firewall address-list\n" +
+				"remove [find list=\"aggregated\"]\n" +
+				"add list=\"aggregated\" address=10.0.0.1/32 comment=\"drop list\" timeout=1d\n" +
+				"add list=\"aggregated\" address=192.168.1.0/24 comment=\"drop list\" timeout=1d\n",
+		},
+		{
+			name:  "Input comments not attached",
+			input: "192.168.0.0/24 ; scanner\n192.168.1.0/24 # brute force\n10.0.0.1\n",
+			opts:  Options{Format: formatMikroTik},
+			wantOutput: "/ip firewall address-list\n" +
+				"remove [find list=\"aggregated\"]\n" +
+				"add list=\"aggregated\" address=10.0.0.1/32\n" +
+				"add list=\"aggregated\" address=192.168.0.0/23\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRouterOSQuote(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "plain", want: `"plain"`},
+		{input: `say "hi"`, want: `"say \"hi\""`},
+		{input: `C:\lists`, want: `"C:\\lists"`},
+		{input: "$var", want: `"\$var"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := routerOSQuote(tt.input); got != tt.want {
+				t.Errorf("routerOSQuote(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMikroTik(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		opts  Options
+		want  string
+	}{
+		{name: "Empty family", cidrs: nil, want: ""},
+		{
+			name:  "First chunk removes the list",
+			cidrs: []string{"10.0.0.0/8"},
+			opts:  Options{chunk: 1},
+			want:  "/ip firewall address-list\nremove [find list=\"aggregated\"]\nadd list=\"aggregated\" address=10.0.0.0/8\n",
+		},
+		{
+			name:  "Later chunk only adds",
+			cidrs: []string{"2001:db8::/32"},
+			opts:  Options{chunk: 2, chunkStart: 1},
+			want:  "/ipv6 firewall address-list\nadd list=\"aggregated\" address=2001:db8::/32\n",
+		},
+		{
+			name:  "List name and comment are quoted",
+			cidrs: []string{"10.0.0.0/8"},
+			opts:  Options{Name: `a "b"`, Comment: "cost $5"},
+			want: "/ip firewall address-list\n" +
+				`remove [find list="a \"b\""]` + "\n" +
+				`add list="a \"b\"" address=10.0.0.0/8 comment="cost \$5"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeMikroTik(&output, mustParseCIDRs(t, tt.cidrs...), tt.opts); err != nil {
+				t.Fatalf("writeMikroTik() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeMikroTik() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	if err := writeMikroTik(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeMikroTik() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestRunWithChunkedMikroTik(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	if err := Run(input, &output, &errOutput, Options{Format: formatMikroTik, ChunkSize: 1}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if got := strings.Count(output.String(), "remove "); got != 1 {
+		t.Errorf("chunked script removes the list %d times, want once:\n%s", got, output.String())
+	}
+	for _, want := range []string{"address=10.0.0.0/24", "address=10.0.2.0/24"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("chunked script is missing %s:\n%s", want, output.String())
+		}
+	}
+}
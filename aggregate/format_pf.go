@@ -0,0 +1,77 @@
+package aggregate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// writePFTable writes a pf table definition. pf tables hold both families,
+// so IPv4 and IPv6 entries share one table.
+func writePFTable(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	cidrs := append(append([]*CIDR{}, ipv4...), ipv6...)
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	entries := make([]string, len(cidrs))
+	for i, c := range cidrs {
+		entries[i] = "\t" + c.String()
+	}
+	_, err := fmt.Fprintf(w, "table <%s> persist {\n%s\n}\n", opts.ListName(), strings.Join(entries, ",\n"))
+	return err
+}
+
+// pfSenseAliasName matches the alias names pfSense accepts.
+var pfSenseAliasName = regexp.MustCompile(`^[a-zA-Z0-9_]{1,31}$`)
+
+// pfSenseAliases is the <aliases> section of a pfSense/OPNsense configuration.
+type pfSenseAliases struct {
+	XMLName xml.Name       `xml:"aliases"`
+	Aliases []pfSenseAlias `xml:"alias"`
+}
+
+// pfSenseAlias is a single network alias. Address holds space-separated
+// networks and Detail the matching "||"-separated per-entry descriptions.
+type pfSenseAlias struct {
+	Name    string `xml:"name"`
+	Type    string `xml:"type"`
+	Address string `xml:"address"`
+	Descr   string `xml:"descr"`
+	Detail  string `xml:"detail"`
+}
+
+// writePFSenseAlias writes a pfSense/OPNsense network alias XML fragment
+// holding both families, for restoring into the aliases configuration area.
+func writePFSenseAlias(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	cidrs := append(append([]*CIDR{}, ipv4...), ipv6...)
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	// A "|" in the description would be read as the detail separator
+	detail := strings.ReplaceAll(opts.Comment, "|", "/")
+	addresses := make([]string, len(cidrs))
+	details := make([]string, len(cidrs))
+	for i, c := range cidrs {
+		addresses[i] = c.String()
+		details[i] = detail
+	}
+
+	doc := pfSenseAliases{Aliases: []pfSenseAlias{{
+		Name:    opts.ListName(),
+		Type:    "network",
+		Address: strings.Join(addresses, " "),
+		Descr:   opts.Comment,
+		Detail:  strings.Join(details, "||"),
+	}}}
+
+	out, err := xml.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
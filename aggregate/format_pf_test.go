@@ -0,0 +1,104 @@
+package aggregate
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunWithPFFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "pf table with both families",
+			input:      "192.168.1.0/24\n10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatPFTable, Name: "bruteforce"},
+			wantOutput: "table <bruteforce> persist {\n\t10.0.0.0/8,\n\t192.168.1.0/24,\n\t2001:db8::/32\n}\n",
+		},
+		{
+			name:       "pf table empty",
+			input:      "",
+			opts:       Options{Format: formatPFTable},
+			wantOutput: "",
+		},
+		{
+			name:  "pfSense alias",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatPFSenseAlias, Name: "blocklist", Comment: "Spamhaus <DROP>"},
+			wantOutput: "<aliases>\n" +
+				"\t<alias>\n" +
+				"\t\t<name>blocklist</name>\n" +
+				"\t\t<type>network</type>\n" +
+				"\t\t<address>192.168.1.0/24 2001:db8::/32</address>\n" +
+				"\t\t<descr>Spamhaus &lt;DROP&gt;</descr>\n" +
+				"\t\t<detail>Spamhaus &lt;DROP&gt;||Spamhaus &lt;DROP&gt;</detail>\n" +
+				"\t</alias>\n" +
+				"</aliases>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWritePFTable(t *testing.T) {
+	var output bytes.Buffer
+	if err := writePFTable(&output, nil, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writePFTable() without networks = %q, %v, want no output", output.String(), err)
+	}
+
+	if err := writePFTable(&output, nil, mustParseCIDRs(t, "2001:db8::/32"), Options{}); err != nil {
+		t.Fatalf("writePFTable() unexpected error: %v", err)
+	}
+	if want := "table <aggregated> persist {\n\t2001:db8::/32\n}\n"; output.String() != want {
+		t.Errorf("writePFTable() = %q, want %q", output.String(), want)
+	}
+
+	if err := writePFTable(failingWriter{}, mustParseCIDRs(t, "10.0.0.0/8"), nil, Options{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writePFTable() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestWritePFSenseAlias(t *testing.T) {
+	var output bytes.Buffer
+	if err := writePFSenseAlias(&output, nil, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writePFSenseAlias() without networks = %q, %v, want no output", output.String(), err)
+	}
+
+	ipv4 := mustParseCIDRs(t, "10.0.0.0/8", "192.168.1.0/24")
+	opts := Options{Name: "blocked", Comment: `R&D <lab> a||b`}
+	if err := writePFSenseAlias(&output, ipv4, nil, opts); err != nil {
+		t.Fatalf("writePFSenseAlias() unexpected error: %v", err)
+	}
+
+	var got pfSenseAliases
+	if err := xml.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, output.String())
+	}
+	alias := got.Aliases[0]
+	if alias.Descr != opts.Comment {
+		t.Errorf("descr = %q, want %q", alias.Descr, opts.Comment)
+	}
+	if want := "R&D <lab> a//b||R&D <lab> a//b"; alias.Detail != want {
+		t.Errorf("detail = %q, want %q (one description per address)", alias.Detail, want)
+	}
+}
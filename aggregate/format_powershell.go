@@ -0,0 +1,73 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// windowsFirewallLimit is the number of remote addresses per Windows
+// firewall rule; rules with larger lists become slow to apply and edit.
+const windowsFirewallLimit = 1000
+
+// writePowerShell writes a PowerShell script that creates or updates one
+// inbound Windows firewall rule per family and batch of networks, then
+// removes rules left over from earlier, longer lists.
+func writePowerShell(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	groups := cloudGroups(ipv4, ipv6, opts.entryLimit(windowsFirewallLimit))
+	if len(groups) == 0 {
+		return nil
+	}
+
+	action := "Allow"
+	if opts.RuleAction() == "deny" {
+		action = "Block"
+	}
+	var description string
+	if opts.Comment != "" {
+		description = " -Description " + powerShellQuote(opts.Comment)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by aggregate-cidr\n$ErrorActionPreference = 'Stop'\n")
+
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = powerShellQuote(group.name(opts))
+		addresses := make([]string, len(group.cidrs))
+		for j, c := range group.cidrs {
+			addresses[j] = powerShellQuote(c.String())
+		}
+
+		fmt.Fprintf(&b, "\n$addresses = @(\n    %s\n)\n", strings.Join(addresses, ",\n    "))
+		fmt.Fprintf(&b, "if (Get-NetFirewallRule -Name %s -ErrorAction SilentlyContinue) {\n", names[i])
+		fmt.Fprintf(&b, "    Set-NetFirewallRule -Name %s -Action %s -RemoteAddress $addresses%s\n", names[i], action, description)
+		b.WriteString("} else {\n")
+		fmt.Fprintf(&b, "    New-NetFirewallRule -Name %s -DisplayName %s -Direction Inbound -Action %s -RemoteAddress $addresses%s | Out-Null\n",
+			names[i], names[i], action, description)
+		b.WriteString("}\n")
+	}
+
+	fmt.Fprintf(&b, "\n$rules = @(%s)\n", strings.Join(names, ", "))
+	pattern := powerShellWildcardEscape(opts.ListName()) + "-ipv*"
+	fmt.Fprintf(&b, "Get-NetFirewallRule -Name %s -ErrorAction SilentlyContinue |\n", powerShellQuote(pattern))
+	b.WriteString("    Where-Object { $rules -notcontains $_.Name } |\n    Remove-NetFirewallRule\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// powerShellQuote returns s as a single-quoted PowerShell string, in which
+// only the quote itself needs escaping, by doubling. PowerShell also reads
+// the typographic single quotes as quotes, so they are doubled too.
+func powerShellQuote(s string) string {
+	r := strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+	return "'" + r.Replace(s) + "'"
+}
+
+// powerShellWildcardEscape escapes the wildcard characters of s with
+// backticks, so a list name cannot widen the pattern that finds its rules.
+func powerShellWildcardEscape(s string) string {
+	r := strings.NewReplacer("`", "``", "*", "`*", "?", "`?", "[", "`[", "]", "`]")
+	return r.Replace(s)
+}
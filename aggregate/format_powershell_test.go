@@ -0,0 +1,97 @@
+package aggregate
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunWithPowerShell(t *testing.T) {
+	input := strings.NewReader("192.168.1.0/24\n10.0.0.1\n2001:db8::/32\n")
+	var output, errOutput bytes.Buffer
+
+	opts := Options{Format: formatPowerShell, Name: "blocklist", Action: "deny", Comment: "Ops' list"}
+	if err := Run(input, &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	want := "# Generated by aggregate-cidr\n$ErrorActionPreference = 'Stop'\n" +
+		"\n$addresses = @(\n    '10.0.0.1/32',\n    '192.168.1.0/24'\n)\n" +
+		"if (Get-NetFirewallRule -Name 'blocklist-ipv4-1' -ErrorAction SilentlyContinue) {\n" +
+		"    Set-NetFirewallRule -Name 'blocklist-ipv4-1' -Action Block -RemoteAddress $addresses -Description 'Ops'' list'\n" +
+		"} else {\n" +
+		"    New-NetFirewallRule -Name 'blocklist-ipv4-1' -DisplayName 'blocklist-ipv4-1' -Direction Inbound -Action Block -RemoteAddress $addresses -Description 'Ops'' list' | Out-Null\n" +
+		"}\n" +
+		"\n$addresses = @(\n    '2001:db8::/32'\n)\n" +
+		"if (Get-NetFirewallRule -Name 'blocklist-ipv6-1' -ErrorAction SilentlyContinue) {\n" +
+		"    Set-NetFirewallRule -Name 'blocklist-ipv6-1' -Action Block -RemoteAddress $addresses -Description 'Ops'' list'\n" +
+		"} else {\n" +
+		"    New-NetFirewallRule -Name 'blocklist-ipv6-1' -DisplayName 'blocklist-ipv6-1' -Direction Inbound -Action Block -RemoteAddress $addresses -Description 'Ops'' list' | Out-Null\n" +
+		"}\n" +
+		"\n$rules = @('blocklist-ipv4-1', 'blocklist-ipv6-1')\n" +
+		"Get-NetFirewallRule -Name 'blocklist-ipv*' -ErrorAction SilentlyContinue |\n" +
+		"    Where-Object { $rules -notcontains $_.Name } |\n" +
+		"    Remove-NetFirewallRule\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
+
+func TestRunWithPowerShellSplitsAtLimit(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < windowsFirewallLimit+5; i++ {
+		fmt.Fprintf(&input, "10.%d.%d.0/24\n", i/128, (i%128)*2)
+	}
+	var output, errOutput bytes.Buffer
+
+	if err := Run(strings.NewReader(input.String()), &output, &errOutput, Options{Format: formatPowerShell}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if got := strings.Count(output.String(), "New-NetFirewallRule"); got != 2 {
+		t.Errorf("script creates %d rules, want 2", got)
+	}
+	if !strings.Contains(output.String(), "$rules = @('aggregated-ipv4-1', 'aggregated-ipv4-2')") {
+		t.Errorf("script does not list both rules:\n%s", output.String()[len(output.String())-300:])
+	}
+	if got := strings.Count(output.String(), "'10."); got != windowsFirewallLimit+5 {
+		t.Errorf("script lists %d addresses, want %d", got, windowsFirewallLimit+5)
+	}
+}
+
+func TestPowerShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"blocklist", "'blocklist'"},
+		{"Ops' list", "'Ops'' list'"},
+		{"Ops\u2019 list", "'Ops\u2019\u2019 list'"},
+		{"$env:PATH `n", "'$env:PATH `n'"},
+		{"", "''"},
+	}
+
+	for _, tt := range tests {
+		if got := powerShellQuote(tt.in); got != tt.want {
+			t.Errorf("powerShellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWritePowerShell(t *testing.T) {
+	var output bytes.Buffer
+	if err := writePowerShell(&output, nil, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writePowerShell() without networks = %q, %v, want no output", output.String(), err)
+	}
+
+	if err := writePowerShell(&output, nil, mustParseCIDRs(t, "2001:db8::/32"), Options{Name: "web[1]*"}); err != nil {
+		t.Fatalf("writePowerShell() unexpected error: %v", err)
+	}
+	if want := "Get-NetFirewallRule -Name 'web`[1`]`*-ipv*'"; !strings.Contains(output.String(), want) {
+		t.Errorf("cleanup does not match only the list's rules, want %q in:\n%s", want, output.String())
+	}
+	if strings.Contains(output.String(), "-Description") {
+		t.Errorf("script sets a description without -comment:\n%s", output.String())
+	}
+}
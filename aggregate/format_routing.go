@@ -0,0 +1,140 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeBIRDPrefixSet writes a BIRD 2 prefix set constant for use in filters.
+// BIRD sets hold a single family, so each family gets its own constant
+// suffixed _v4 or _v6. The -ge/-le bounds become {low,high} length ranges.
+func writeBIRDPrefixSet(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "define %s = [\n", birdName(cidrs[0], opts)); err != nil {
+		return err
+	}
+	for i, c := range cidrs {
+		sep := ","
+		if i == len(cidrs)-1 {
+			sep = ""
+		}
+		if _, err := fmt.Fprintf(w, "    %s%s%s\n", c, birdLengthRange(c, opts), sep); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "];\n")
+	return err
+}
+
+// writeBIRDStatic writes a BIRD 2 static protocol announcing each network,
+// as a blackhole unless a next-hop is configured for the family.
+// A configured community is attached to every route.
+func writeBIRDStatic(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "protocol static %s {\n    %s;\n", birdName(cidrs[0], opts), familyName(cidrs[0])); err != nil {
+		return err
+	}
+
+	target := "blackhole"
+	if nextHop := opts.NextHopFor(cidrs[0]); nextHop != "" {
+		target = "via " + nextHop
+	}
+	attrs := ";"
+	if opts.Community != "" {
+		attrs = fmt.Sprintf(" { bgp_community.add((%s)); };", strings.Replace(opts.Community, ":", ",", 1))
+	}
+
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "    route %s %s%s\n", c, target, attrs); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "}\n")
+	return err
+}
+
+// writeFRRPrefixList writes an FRRouting prefix-list, which shares the Cisco
+// syntax. A configured community adds a route-map that sets it on matching
+// routes, for use with "redistribute static route-map NAME".
+func writeFRRPrefixList(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if err := writeCiscoPrefixList(w, cidrs, opts); err != nil {
+		return err
+	}
+	if len(cidrs) == 0 || opts.Community == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "route-map %s permit %d\n match %s address prefix-list %s\n set community %s additive\n",
+		opts.ListName(), routeMapSeq(cidrs[0]), ciscoFamily(cidrs[0]), opts.ListName(), opts.Community)
+	return err
+}
+
+// writeFRRStatic writes FRRouting static routes, as blackholes unless
+// a next-hop is configured for the family.
+func writeFRRStatic(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		target := "blackhole"
+		if nextHop := opts.NextHopFor(c); nextHop != "" {
+			target = nextHop
+		}
+		if _, err := fmt.Fprintf(w, "%s route %s %s\n", ciscoFamily(c), c, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// birdName returns the family-specific BIRD identifier for the list.
+// BIRD identifiers cannot contain dashes, so they become underscores.
+// Chunks are numbered, since BIRD rejects a second definition of a name.
+func birdName(c *CIDR, opts Options) string {
+	name := strings.ReplaceAll(opts.ListName(), "-", "_")
+	if c.bits == 32 {
+		name += "_v4"
+	} else {
+		name += "_v6"
+	}
+	if opts.chunk > 0 {
+		name += fmt.Sprintf("_%d", opts.chunk)
+	}
+	return name
+}
+
+// birdLengthRange returns the {low,high} prefix length pattern for the
+// -ge/-le bounds, or an empty string when neither applies to c.
+func birdLengthRange(c *CIDR, opts Options) string {
+	low, high := c.ones, c.ones
+	if opts.GE > c.ones && opts.GE <= c.bits {
+		low, high = opts.GE, c.bits // ge alone matches up to the longest prefix
+	}
+	if opts.LE > c.ones && opts.LE <= c.bits && opts.LE >= low {
+		high = opts.LE
+	}
+	if low == c.ones && high == c.ones {
+		return ""
+	}
+	return fmt.Sprintf("{%d,%d}", low, high)
+}
+
+// routeMapSeq keeps the IPv4 and IPv6 route-map entries from colliding.
+func routeMapSeq(c *CIDR) int {
+	if c.bits == 32 {
+		return 10
+	}
+	return 20
+}
+
+// familyName returns "ipv4" or "ipv6" for the network.
+func familyName(c *CIDR) string {
+	if c.bits == 32 {
+		return "ipv4"
+	}
+	return "ipv6"
+}
@@ -0,0 +1,191 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunWithRoutingFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "BIRD prefix sets per family",
+			input: "192.168.1.0/24\n10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatBIRDPrefixSet, Name: "drop-list"},
+			wantOutput: "define drop_list_v4 = [\n    10.0.0.0/8,\n    192.168.1.0/24\n];\n" +
+				"define drop_list_v6 = [\n    2001:db8::/32\n];\n",
+		},
+		{
+			name:       "BIRD prefix set with ge and le",
+			input:      "10.0.0.0/8\n192.168.1.0/24\n",
+			opts:       Options{Format: formatBIRDPrefixSet, GE: 16, LE: 24},
+			wantOutput: "define aggregated_v4 = [\n    10.0.0.0/8{16,24},\n    192.168.1.0/24\n];\n",
+		},
+		{
+			name:       "BIRD prefix set with ge only",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatBIRDPrefixSet, GE: 16},
+			wantOutput: "define aggregated_v4 = [\n    10.0.0.0/8{16,32}\n];\n",
+		},
+		{
+			name:  "BIRD static blackholes",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatBIRDStatic},
+			wantOutput: "protocol static aggregated_v4 {\n    ipv4;\n    route 192.168.1.0/24 blackhole;\n}\n" +
+				"protocol static aggregated_v6 {\n    ipv6;\n    route 2001:db8::/32 blackhole;\n}\n",
+		},
+		{
+			name:  "BIRD static with next-hop and community",
+			input: "192.168.1.0/24\n",
+			opts:  Options{Format: formatBIRDStatic, NextHop4: "192.0.2.1", Community: "65535:666"},
+			wantOutput: "protocol static aggregated_v4 {\n    ipv4;\n" +
+				"    route 192.168.1.0/24 via 192.0.2.1 { bgp_community.add((65535,666)); };\n}\n",
+		},
+		{
+			name:  "FRR prefix-list",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatFRRPrefixList, Name: "RTBH"},
+			wantOutput: "ip prefix-list RTBH seq 5 permit 192.168.1.0/24\n" +
+				"ipv6 prefix-list RTBH seq 5 permit 2001:db8::/32\n",
+		},
+		{
+			name:  "FRR prefix-list with community route-map",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatFRRPrefixList, Name: "RTBH", Community: "65535:666"},
+			wantOutput: "ip prefix-list RTBH seq 5 permit 192.168.1.0/24\n" +
+				"route-map RTBH permit 10\n match ip address prefix-list RTBH\n set community 65535:666 additive\n" +
+				"ipv6 prefix-list RTBH seq 5 permit 2001:db8::/32\n" +
+				"route-map RTBH permit 20\n match ipv6 address prefix-list RTBH\n set community 65535:666 additive\n",
+		},
+		{
+			name:  "FRR static routes",
+			input: "192.168.1.0/24\n2001:db8::/32\n",
+			opts:  Options{Format: formatFRRStatic, NextHop6: "2001:db8:ffff::1"},
+			wantOutput: "ip route 192.168.1.0/24 blackhole\n" +
+				"ipv6 route 2001:db8::/32 2001:db8:ffff::1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestBIRDName(t *testing.T) {
+	tests := []struct {
+		name string
+		cidr string
+		opts Options
+		want string
+	}{
+		{name: "Default IPv4", cidr: "10.0.0.0/8", want: "aggregated_v4"},
+		{name: "Dashes replaced", cidr: "2001:db8::/32", opts: Options{Name: "drop-list"}, want: "drop_list_v6"},
+		{name: "Chunk numbered", cidr: "10.0.0.0/8", opts: Options{chunk: 2, chunkStart: 100}, want: "aggregated_v4_2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := birdName(mustParseCIDRs(t, tt.cidr)[0], tt.opts); got != tt.want {
+				t.Errorf("birdName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteBIRDAndFRR(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(io.Writer, []*CIDR, Options) error
+		cidrs []string
+		opts  Options
+		want  string
+	}{
+		{
+			name:  "BIRD prefix set empty family",
+			write: writeBIRDPrefixSet,
+			want:  "",
+		},
+		{
+			name:  "BIRD static empty family",
+			write: writeBIRDStatic,
+			want:  "",
+		},
+		{
+			name:  "BIRD static next-hop and community",
+			write: writeBIRDStatic,
+			cidrs: []string{"2001:db8::/32"},
+			opts:  Options{NextHop6: "2001:db8::ff", Community: "65535:666", chunk: 3},
+			want:  "protocol static aggregated_v6_3 {\n    ipv6;\n    route 2001:db8::/32 via 2001:db8::ff { bgp_community.add((65535,666)); };\n}\n",
+		},
+		{
+			name:  "FRR prefix-list without community has no route-map",
+			write: writeFRRPrefixList,
+			cidrs: []string{"2001:db8::/32"},
+			want:  "ipv6 prefix-list aggregated seq 5 permit 2001:db8::/32\n",
+		},
+		{
+			name:  "FRR prefix-list empty family with community",
+			write: writeFRRPrefixList,
+			opts:  Options{Community: "65535:666"},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := tt.write(&output, mustParseCIDRs(t, tt.cidrs...), tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("output = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	cidrs := mustParseCIDRs(t, "10.0.0.0/8")
+	for name, write := range map[string]func() error{
+		"writeBIRDPrefixSet": func() error { return writeBIRDPrefixSet(failingWriter{}, cidrs, Options{}) },
+		"writeBIRDStatic":    func() error { return writeBIRDStatic(failingWriter{}, cidrs, Options{}) },
+		"writeFRRPrefixList": func() error { return writeFRRPrefixList(failingWriter{}, cidrs, Options{Community: "65535:666"}) },
+		"writeFRRStatic":     func() error { return writeFRRStatic(failingWriter{}, cidrs, Options{}) },
+	} {
+		if err := write(); !errors.Is(err, errWriteFailed) {
+			t.Errorf("%s() error = %v, want %v", name, err, errWriteFailed)
+		}
+	}
+}
+
+func TestRunWithChunkedBIRDPrefixSet(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	if err := Run(input, &output, &errOutput, Options{Format: formatBIRDPrefixSet, ChunkSize: 1}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	want := "# ipv4 chunk 1/2 (1 entries)\ndefine aggregated_v4_1 = [\n    10.0.0.0/24\n];\n" +
+		"# ipv4 chunk 2/2 (1 entries)\ndefine aggregated_v4_2 = [\n    10.0.2.0/24\n];\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
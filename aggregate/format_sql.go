@@ -0,0 +1,153 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writePostgresCopy writes PostgreSQL COPY data loading both families into
+// the table named by -name, whose network column has type cidr.
+func writePostgresCopy(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	cidrs := append(append([]*CIDR{}, ipv4...), ipv6...)
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	columns := strings.Join(sqlColumns(opts, "network"), ", ")
+	if _, err := fmt.Fprintf(w, "COPY %s (%s) FROM stdin;\n", sqlIdentifier(opts.ListName()), columns); err != nil {
+		return err
+	}
+	for _, c := range cidrs {
+		fields := []string{c.String()}
+		for _, value := range sqlExtraValues(c, opts) {
+			fields = append(fields, copyField(value))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, `\.`)
+	return err
+}
+
+// writePostgresInsert writes one INSERT statement adding both families to
+// the table named by -name, whose network column has type cidr.
+func writePostgresInsert(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	cidrs := append(append([]*CIDR{}, ipv4...), ipv6...)
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	rows := make([][]string, len(cidrs))
+	for i, c := range cidrs {
+		rows[i] = []string{sqlLiteral(c.String())}
+	}
+	return writeInsert(w, opts.ListName(), sqlColumns(opts, "network"), rows, cidrs, opts)
+}
+
+// writeSQLRange writes an INSERT statement for databases without network
+// types, giving each network's first and last addresses as integers for
+// IPv4 and 16-byte binary strings for IPv6, so lookups can use BETWEEN.
+// The families go to separate NAME_v4 and NAME_v6 tables as their column
+// types differ. The X'...' blob literals and double-quoted identifiers
+// suit SQLite, and MySQL or MariaDB in ANSI_QUOTES mode; PostgreSQL reads
+// X'...' as a bit string, so it is served by the postgres formats instead.
+func writeSQLRange(w io.Writer, cidrs []*CIDR, opts Options) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	table := opts.ListName() + "_v4"
+	if cidrs[0].bits == 128 {
+		table = opts.ListName() + "_v6"
+	}
+
+	rows := make([][]string, len(cidrs))
+	for i, c := range cidrs {
+		first, last := c.bounds()
+		if c.bits == 32 {
+			rows[i] = []string{sqlLiteral(c.String()), first.String(), last.String()}
+		} else {
+			rows[i] = []string{
+				sqlLiteral(c.String()),
+				fmt.Sprintf("X'%x'", []byte(bigIntToIP(first, c.bits))),
+				fmt.Sprintf("X'%x'", []byte(bigIntToIP(last, c.bits))),
+			}
+		}
+	}
+	return writeInsert(w, table, sqlColumns(opts, "network", "first_ip", "last_ip"), rows, cidrs, opts)
+}
+
+// writeInsert writes a multi-row INSERT statement, appending the optional
+// source and annotation values of each network to its row.
+func writeInsert(w io.Writer, table string, columns []string, rows [][]string, cidrs []*CIDR, opts Options) error {
+	if _, err := fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES\n", sqlIdentifier(table), strings.Join(columns, ", ")); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		for _, value := range sqlExtraValues(cidrs[i], opts) {
+			row = append(row, sqlLiteral(value))
+		}
+		end := ","
+		if i == len(rows)-1 {
+			end = ";"
+		}
+		if _, err := fmt.Fprintf(w, "  (%s)%s\n", strings.Join(row, ", "), end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlColumns returns the given columns followed by the optional source and
+// annotation columns.
+func sqlColumns(opts Options, columns ...string) []string {
+	if opts.Source != "" {
+		columns = append(columns, "source")
+	}
+	if opts.SQLAnnotation {
+		columns = append(columns, "annotation")
+	}
+	return columns
+}
+
+// sqlExtraValues returns the values of the optional source and annotation
+// columns for c.
+func sqlExtraValues(c *CIDR, opts Options) []string {
+	var values []string
+	if opts.Source != "" {
+		values = append(values, opts.Source)
+	}
+	if opts.SQLAnnotation {
+		values = append(values, opts.EntryComment(c))
+	}
+	return values
+}
+
+// sqlIdentifier quotes a possibly schema-qualified table name.
+func sqlIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// sqlLiteral returns s as a SQL string literal, or NULL when s is empty.
+func sqlLiteral(s string) string {
+	if s == "" {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// copyField escapes s for a COPY text-format field, writing empty values
+// as NULL.
+func copyField(s string) string {
+	if s == "" {
+		return `\N`
+	}
+	r := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	return r.Replace(s)
+}
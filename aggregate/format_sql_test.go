@@ -0,0 +1,131 @@
+package aggregate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunWithSQLFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:  "COPY",
+			input: "10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatPostgresCopy, Name: "blocklist"},
+			wantOutput: "COPY \"blocklist\" (network) FROM stdin;\n" +
+				"10.0.0.0/8\n2001:db8::/32\n\\.\n",
+		},
+		{
+			name:  "COPY with source and annotation",
+			input: "10.0.0.0/8 ; scan\\probe\n2001:db8::/32\n",
+			opts:  Options{Format: formatPostgresCopy, Name: "intel.blocklist", Source: "feed", SQLAnnotation: true},
+			wantOutput: "COPY \"intel\".\"blocklist\" (network, source, annotation) FROM stdin;\n" +
+				"10.0.0.0/8\tfeed\tscan\\\\probe\n2001:db8::/32\tfeed\t\\N\n\\.\n",
+		},
+		{
+			name:  "INSERT",
+			input: "10.0.0.0/8 ; o'clock\n2001:db8::/32\n",
+			opts:  Options{Format: formatPostgresInsert, SQLAnnotation: true},
+			wantOutput: "INSERT INTO \"aggregated\" (network, annotation) VALUES\n" +
+				"  ('10.0.0.0/8', 'o''clock'),\n" +
+				"  ('2001:db8::/32', NULL);\n",
+		},
+		{
+			name:       "INSERT empty",
+			input:      "",
+			opts:       Options{Format: formatPostgresInsert},
+			wantOutput: "",
+		},
+		{
+			name:  "Integer and binary ranges",
+			input: "10.0.0.0/8\n2001:db8::/32\n",
+			opts:  Options{Format: formatSQLRange, Source: "feed"},
+			wantOutput: "INSERT INTO \"aggregated_v4\" (network, first_ip, last_ip, source) VALUES\n" +
+				"  ('10.0.0.0/8', 167772160, 184549375, 'feed');\n" +
+				"INSERT INTO \"aggregated_v6\" (network, first_ip, last_ip, source) VALUES\n" +
+				"  ('2001:db8::/32', X'20010db8000000000000000000000000', X'20010db8ffffffffffffffffffffffff', 'feed');\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestSQLQuoting(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "Identifier", got: sqlIdentifier("blocklist"), want: `"blocklist"`},
+		{name: "Schema-qualified identifier", got: sqlIdentifier("intel.block list"), want: `"intel"."block list"`},
+		{name: "Identifier with quote", got: sqlIdentifier(`a"b`), want: `"a""b"`},
+		{name: "Literal with quote", got: sqlLiteral("o'clock"), want: `'o''clock'`},
+		{name: "Empty literal", got: sqlLiteral(""), want: "NULL"},
+		{name: "COPY field escapes", got: copyField("a\tb\nc\\d\r"), want: `a\tb\nc\\d\r`},
+		{name: "Empty COPY field", got: copyField(""), want: `\N`},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestWriteSQLRange(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeSQLRange(&output, nil, Options{}); err != nil || output.Len() != 0 {
+		t.Errorf("writeSQLRange() without networks = %q, %v, want no output", output.String(), err)
+	}
+
+	if err := writeSQLRange(&output, mustParseCIDRs(t, "::1", "2001:db8::/127"), Options{Name: "intel.blocked"}); err != nil {
+		t.Fatalf("writeSQLRange() unexpected error: %v", err)
+	}
+	want := "INSERT INTO \"intel\".\"blocked_v6\" (network, first_ip, last_ip) VALUES\n" +
+		"  ('::1/128', X'00000000000000000000000000000001', X'00000000000000000000000000000001'),\n" +
+		"  ('2001:db8::/127', X'20010db8000000000000000000000000', X'20010db8000000000000000000000001');\n"
+	if output.String() != want {
+		t.Errorf("writeSQLRange() = %q, want %q", output.String(), want)
+	}
+}
+
+func TestRunWithChunkedSQLRange(t *testing.T) {
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	opts := Options{Format: formatSQLRange, ChunkSize: 2}
+	if err := Run(input, &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	// Each chunk is a complete statement
+	want := "-- ipv4 chunk 1/2 (2 entries)\n" +
+		"INSERT INTO \"aggregated_v4\" (network, first_ip, last_ip) VALUES\n" +
+		"  ('10.0.0.0/24', 167772160, 167772415),\n" +
+		"  ('10.0.2.0/24', 167772672, 167772927);\n" +
+		"-- ipv4 chunk 2/2 (1 entries)\n" +
+		"INSERT INTO \"aggregated_v4\" (network, first_ip, last_ip) VALUES\n" +
+		"  ('10.0.4.0/24', 167773184, 167773439);\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+}
@@ -0,0 +1,130 @@
+package aggregate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is the value a -template file is executed with.
+type templateData struct {
+	Networks  []templateNetwork // both families, IPv4 first
+	IPv4      []templateNetwork
+	IPv6      []templateNetwork
+	Name      string // -name or the default list name
+	Action    string // permit or deny
+	Comment   string
+	Source    string
+	Generated time.Time
+}
+
+// templateNetwork describes one aggregated network to a template.
+type templateNetwork struct {
+	Index       int    // position in the list holding it, from 0
+	CIDR        string // 192.0.2.0/24
+	Network     string // 192.0.2.0
+	Prefix      int    // 24
+	Mask        string // 255.255.255.0
+	Wildcard    string // 0.0.0.255
+	First       string // 192.0.2.0
+	Last        string // 192.0.2.255
+	Size        *big.Int
+	Family      string // ipv4 or ipv6
+	Bits        int    // 32 or 128
+	Annotations []string
+	Comment     string // -comment, or else the annotations joined by "; "
+}
+
+// templateFuncs are the helper functions available to -template files.
+var templateFuncs = template.FuncMap{
+	"join":    func(sep string, s []string) string { return strings.Join(s, sep) },
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"replace": func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	"trim":    strings.TrimSpace,
+	"quote":   func(s string) string { return fmt.Sprintf("%q", s) },
+	"add":     func(a, b int) int { return a + b },
+	"sub":     func(a, b int) int { return a - b },
+	"last":    func(i int, list any) bool { return templateLen(list)-1 == i },
+}
+
+// writeTemplate executes the text/template file named by -template with
+// the networks of both families and the list settings. The result is only
+// written once the template has run to completion, so a failing template
+// leaves no partial output.
+func writeTemplate(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	text, err := os.ReadFile(opts.Template)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(filepath.Base(opts.Template)).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	data := templateData{
+		Name:      opts.ListName(),
+		Action:    opts.RuleAction(),
+		Comment:   opts.Comment,
+		Source:    opts.FeedSource(),
+		Generated: time.Now().UTC(),
+	}
+	// Each list numbers its own networks, so Index works with "last" on
+	// whichever list a template iterates over
+	for i, c := range ipv4 {
+		data.IPv4 = append(data.IPv4, newTemplateNetwork(i, c, opts))
+	}
+	for i, c := range ipv6 {
+		data.IPv6 = append(data.IPv6, newTemplateNetwork(i, c, opts))
+	}
+	for _, n := range append(append([]templateNetwork{}, data.IPv4...), data.IPv6...) {
+		n.Index = len(data.Networks)
+		data.Networks = append(data.Networks, n)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return err
+	}
+	_, err = b.WriteTo(w)
+	return err
+}
+
+// newTemplateNetwork describes c, the network at index i.
+func newTemplateNetwork(i int, c *CIDR, opts Options) templateNetwork {
+	first, last := c.bounds()
+	size := new(big.Int).Sub(last, first)
+	return templateNetwork{
+		Index:       i,
+		CIDR:        c.String(),
+		Network:     c.ip.String(),
+		Prefix:      c.ones,
+		Mask:        c.Netmask().String(),
+		Wildcard:    c.Wildcard().String(),
+		First:       bigIntToIP(first, c.bits).String(),
+		Last:        bigIntToIP(last, c.bits).String(),
+		Size:        size.Add(size, big.NewInt(1)),
+		Family:      familyName(c),
+		Bits:        c.bits,
+		Annotations: c.annotations,
+		Comment:     opts.EntryComment(c),
+	}
+}
+
+// templateLen returns the length of a list passed to the "last" helper.
+func templateLen(list any) int {
+	switch l := list.(type) {
+	case []templateNetwork:
+		return len(l)
+	case []string:
+		return len(l)
+	default:
+		return 0
+	}
+}
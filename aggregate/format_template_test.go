@@ -0,0 +1,119 @@
+package aggregate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWithTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "Network fields",
+			template:   "{{range .Networks}}{{.Index}} {{.Family}} {{.Network}}/{{.Prefix}} {{.Mask}} {{.Wildcard}} {{.First}}-{{.Last}} {{.Size}}\n{{end}}",
+			input:      "192.168.1.0/24\n10.0.0.1\n",
+			wantOutput: "0 ipv4 10.0.0.1/32 255.255.255.255 0.0.0.0 10.0.0.1-10.0.0.1 1\n1 ipv4 192.168.1.0/24 255.255.255.0 0.0.0.255 192.168.1.0-192.168.1.255 256\n",
+		},
+		{
+			name:       "Families and metadata",
+			template:   "{{.Name}} {{.Action}}: {{len .IPv4}} IPv4, {{len .IPv6}} IPv6{{range .IPv6}} {{.CIDR}} {{.Bits}}{{end}}\n",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Name: "blocklist", Action: "deny"},
+			wantOutput: "blocklist deny: 1 IPv4, 1 IPv6 2001:db8::/32 128\n",
+		},
+		{
+			name:       "Annotations and comments",
+			template:   "{{range .Networks}}{{.CIDR}} [{{join \",\" .Annotations}}] {{.Comment}}\n{{end}}",
+			input:      "10.0.0.0/25 ; scanner\n10.0.0.128/25 ; bot\n2001:db8::/32\n",
+			wantOutput: "10.0.0.0/24 [scanner,bot] scanner; bot\n2001:db8::/32 [] \n",
+		},
+		{
+			name:       "Families iterated separately",
+			template:   "v4 {{range .IPv4}}{{.CIDR}}{{if not (last .Index $.IPv4)}},{{end}}{{end}}\nv6 {{range .IPv6}}{{.CIDR}}{{if not (last .Index $.IPv6)}},{{end}}{{end}}\nall {{range .Networks}}{{.Index}}{{end}}\n",
+			input:      "10.0.0.0/8\n192.168.0.0/16\n2001:db8::/32\n2001:dba::/32\n",
+			wantOutput: "v4 10.0.0.0/8,192.168.0.0/16\nv6 2001:db8::/32,2001:dba::/32\nall 0123\n",
+		},
+		{
+			name:       "Helper functions",
+			template:   "{{range $i, $n := .Networks}}{{quote $n.CIDR}}{{if not (last $i $.Networks)}}, {{end}}{{end}} {{upper .Name}} {{replace \"-\" \"_\" .Name}} {{add 1 2}} {{sub 5 3}} {{trim \" x \"}} {{lower \"ABC\"}}\n",
+			input:      "10.0.0.0/8\n192.168.0.0/16\n",
+			opts:       Options{Name: "block-list"},
+			wantOutput: "\"10.0.0.0/8\", \"192.168.0.0/16\" BLOCK-LIST block_list 3 2 x abc\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "list.tmpl")
+			if err := os.WriteFile(file, []byte(tt.template), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			tt.opts.Format = formatTemplate
+			tt.opts.Template = file
+
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v (%s)", err, errOutput.String())
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.tmpl")
+	if err := os.WriteFile(invalid, []byte("{{range .Networks}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unknownField := filepath.Join(dir, "unknown.tmpl")
+	if err := os.WriteFile(unknownField, []byte("{{.Bogus}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lateError := filepath.Join(dir, "late.tmpl")
+	if err := os.WriteFile(lateError, []byte("# {{.Name}}\n{{range .Networks}}{{.CIDR}}\n{{end}}{{index .Networks 5}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{name: "Missing file", template: filepath.Join(dir, "missing.tmpl")},
+		{name: "Parse error", template: invalid},
+		{name: "Unknown field", template: unknownField},
+		{name: "Error after output", template: lateError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader("10.0.0.0/8\n")
+			var output, errOutput bytes.Buffer
+
+			err := Run(input, &output, &errOutput, Options{Format: formatTemplate, Template: tt.template})
+			if err == nil {
+				t.Fatal("Run() expected error, got nil")
+			}
+			if !strings.Contains(errOutput.String(), "error writing output") {
+				t.Errorf("Run() stderr = %q, want output error", errOutput.String())
+			}
+			if output.Len() != 0 {
+				t.Errorf("Run() wrote partial output %q", output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+)
+
+// defaultMapValue is the value given to listed networks in key/value map
+// formats when -value is not given.
+const defaultMapValue = "1"
+
+// writeNginx writes nginx access module rules, one allow or deny directive
+// per network. Permit lists end with "deny all;", as nginx lets through
+// any client no rule matches.
+func writeNginx(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil
+	}
+
+	directive := "allow"
+	if opts.RuleAction() == "deny" {
+		directive = "deny"
+	}
+	for _, c := range append(append([]*CIDR{}, ipv4...), ipv6...) {
+		if _, err := fmt.Fprintf(w, "%s %s;\n", directive, c); err != nil {
+			return err
+		}
+	}
+	if directive == "deny" {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, "deny all;")
+	return err
+}
+
+// writeNginxGeo writes an nginx geo block that sets $NAME to the configured
+// value for listed clients and to 0 for everyone else.
+func writeNginxGeo(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "geo $%s {\n    default 0;\n", opts.ListName()); err != nil {
+		return err
+	}
+	value := opts.MapValue(defaultMapValue)
+	for _, c := range append(append([]*CIDR{}, ipv4...), ipv6...) {
+		if _, err := fmt.Fprintf(w, "    %s %s;\n", c, value); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// writeApache writes an Apache 2.4 authorisation block. Permit lists grant
+// access to any listed network; deny lists grant access to everyone except
+// the listed networks, which needs a RequireAll container to take effect.
+func writeApache(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil
+	}
+
+	container, require := "RequireAny", "Require ip"
+	if opts.RuleAction() == "deny" {
+		container, require = "RequireAll", "Require not ip"
+	}
+
+	if _, err := fmt.Fprintf(w, "<%s>\n", container); err != nil {
+		return err
+	}
+	if opts.RuleAction() == "deny" {
+		if _, err := fmt.Fprintln(w, "    Require all granted"); err != nil {
+			return err
+		}
+	}
+	for _, c := range append(append([]*CIDR{}, ipv4...), ipv6...) {
+		if _, err := fmt.Fprintf(w, "    %s %s\n", require, c); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "</%s>\n", container)
+	return err
+}
+
+// writeHAProxyMap writes an HAProxy map file pairing each network with the
+// configured value, for use with map_ip() or the ip converter.
+func writeHAProxyMap(w io.Writer, cidrs []*CIDR, opts Options) error {
+	value := opts.MapValue(defaultMapValue)
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "%s %s\n", c, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSquidACL writes Squid src ACL lines adding each network to the
+// named ACL, for inclusion in squid.conf.
+func writeSquidACL(w io.Writer, cidrs []*CIDR, opts Options) error {
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintf(w, "acl %s src %s\n", opts.ListName(), c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package aggregate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunWithWebFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "nginx allow",
+			input:      "192.168.1.0/24\n192.168.0.0/24\n2001:db8::/32\n",
+			opts:       Options{Format: formatNginx},
+			wantOutput: "allow 192.168.0.0/23;\nallow 2001:db8::/32;\ndeny all;\n",
+		},
+		{
+			name:       "nginx deny",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatNginx, Action: "deny"},
+			wantOutput: "deny 10.0.0.0/8;\n",
+		},
+		{
+			name:       "nginx geo",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatNginxGeo, Name: "blocked"},
+			wantOutput: "geo $blocked {\n    default 0;\n    10.0.0.0/8 1;\n    2001:db8::/32 1;\n}\n",
+		},
+		{
+			name:       "nginx geo with value",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatNginxGeo, Value: "bad"},
+			wantOutput: "geo $aggregated {\n    default 0;\n    10.0.0.0/8 bad;\n}\n",
+		},
+		{
+			name:       "nginx geo empty",
+			input:      "",
+			opts:       Options{Format: formatNginxGeo},
+			wantOutput: "",
+		},
+		{
+			name:       "Apache permit",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatApache},
+			wantOutput: "<RequireAny>\n    Require ip 10.0.0.0/8\n    Require ip 2001:db8::/32\n</RequireAny>\n",
+		},
+		{
+			name:       "Apache deny",
+			input:      "10.0.0.0/8\n",
+			opts:       Options{Format: formatApache, Action: "deny"},
+			wantOutput: "<RequireAll>\n    Require all granted\n    Require not ip 10.0.0.0/8\n</RequireAll>\n",
+		},
+		{
+			name:       "HAProxy ACL file",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatHAProxyACL},
+			wantOutput: "10.0.0.0/8\n2001:db8::/32\n",
+		},
+		{
+			name:       "HAProxy map file",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatHAProxyMap, Value: "blocklist"},
+			wantOutput: "10.0.0.0/8 blocklist\n2001:db8::/32 blocklist\n",
+		},
+		{
+			name:       "Squid ACL",
+			input:      "10.0.0.0/8\n2001:db8::/32\n",
+			opts:       Options{Format: formatSquidACL, Name: "blocked_src"},
+			wantOutput: "acl blocked_src src 10.0.0.0/8\nacl blocked_src src 2001:db8::/32\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, tt.opts); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteNginx(t *testing.T) {
+	ipv4 := mustParseCIDRs(t, "10.0.0.0/8")
+	ipv6 := mustParseCIDRs(t, "2001:db8::/32")
+
+	tests := []struct {
+		name       string
+		ipv4, ipv6 []*CIDR
+		opts       Options
+		wantOutput string
+	}{
+		{name: "Permit ends with deny all", ipv4: ipv4, ipv6: ipv6, wantOutput: "allow 10.0.0.0/8;\nallow 2001:db8::/32;\ndeny all;\n"},
+		{name: "Permit IPv6 only", ipv6: ipv6, wantOutput: "allow 2001:db8::/32;\ndeny all;\n"},
+		{name: "Deny leaves other clients allowed", ipv4: ipv4, opts: Options{Action: "deny"}, wantOutput: "deny 10.0.0.0/8;\n"},
+		{name: "No networks", wantOutput: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeNginx(&output, tt.ipv4, tt.ipv6, tt.opts); err != nil {
+				t.Fatalf("writeNginx() unexpected error: %v", err)
+			}
+			if output.String() != tt.wantOutput {
+				t.Errorf("writeNginx() = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
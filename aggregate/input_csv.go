@@ -0,0 +1,166 @@
+package aggregate
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvReader reads networks from one column of CSV or TSV records. Each
+// network field is parsed like an input line, so any supported notation
+// may appear in it, and the values of the annotation columns are attached
+// to the networks it yields.
+type csvReader struct {
+	comma rune
+}
+
+// ReadNetworks reads every record of input, reporting records whose network
+// field fails to parse to errOutput and skipping them. Columns are chosen
+// with -column and -annotation-columns, by 1-based index or by header name;
+// naming a column, or -header, treats the first record as a header.
+func (r csvReader) ReadNetworks(input io.Reader, errOutput io.Writer, opts Options) ([]*CIDR, error) {
+	cr := csv.NewReader(input)
+	cr.Comma = r.comma
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	annotate := opts.keepsAnnotations()
+	specs := append([]string{opts.networkColumn()}, csvColumnSpecs(opts.AnnotationColumns)...)
+	var header []string
+	if opts.Header || csvNamesColumn(specs) {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		header = append([]string{}, record...)
+		header[0] = strings.TrimPrefix(header[0], "\ufeff") // spreadsheet byte order mark
+	}
+
+	columns := make([]int, len(specs))
+	for i, spec := range specs {
+		col, err := csvColumn(spec, header)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = col
+	}
+
+	var cidrs []*CIDR
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return cidrs, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			_, _ = fmt.Fprintf(errOutput, "line %d: %v\n", parseErr.StartLine, parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		if columns[0] >= len(record) {
+			_, _ = fmt.Fprintf(errOutput, "line %d: no column %d in record\n", line, columns[0]+1)
+			continue
+		}
+		field := record[columns[0]]
+		parsed, err := parseInputAs(field, "")
+		if err != nil {
+			line, _ = cr.FieldPos(columns[0])
+			_, _ = fmt.Fprintf(errOutput, "line %d: %v\n", line, err)
+			continue
+		}
+
+		if !annotate {
+			cidrs = append(cidrs, parsed...)
+			continue
+		}
+		var annotations []string
+		if annotation := lineAnnotation(field); annotation != "" {
+			annotations = append(annotations, annotation)
+		}
+		for _, col := range columns[1:] {
+			if col < len(record) {
+				if value := strings.TrimSpace(record[col]); value != "" {
+					annotations = append(annotations, value)
+				}
+			}
+		}
+		for _, c := range parsed {
+			c.annotations = annotations
+		}
+		cidrs = append(cidrs, parsed...)
+	}
+}
+
+// defaultColumn is the network column when -column is not given.
+const defaultColumn = "1"
+
+// networkColumn returns the configured network column or the first column.
+func (o *Options) networkColumn() string {
+	if o.Column == "" {
+		return defaultColumn
+	}
+	return o.Column
+}
+
+// csvColumnSpecs splits a comma-separated list of column indexes and names.
+func csvColumnSpecs(list string) []string {
+	if list == "" {
+		return nil
+	}
+	specs := strings.Split(list, ",")
+	for i, spec := range specs {
+		specs[i] = strings.TrimSpace(spec)
+	}
+	return specs
+}
+
+// csvNamePrefix marks a column as a header name even when it is a number,
+// so numeric headers such as years can be selected: name:2024.
+const csvNamePrefix = "name:"
+
+// csvNamesColumn reports whether any column is given by header name.
+func csvNamesColumn(specs []string) bool {
+	for _, spec := range specs {
+		if _, ok := csvIndex(spec); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// csvIndex reports whether a column is given by index, as a bare number,
+// and returns the index 0-based.
+func csvIndex(spec string) (int, bool) {
+	n, err := strconv.Atoi(spec)
+	return n - 1, err == nil
+}
+
+// csvColumn resolves a 1-based column index or a header name, matched
+// without regard to case, to a 0-based field index.
+func csvColumn(spec string, header []string) (int, error) {
+	if n, ok := csvIndex(spec); ok {
+		if n < 0 {
+			return 0, fmt.Errorf("invalid column index %q", spec)
+		}
+		return n, nil
+	}
+	spec = strings.TrimPrefix(spec, csvNamePrefix)
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), spec) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("column %q not found in header", spec)
+}
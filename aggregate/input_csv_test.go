@@ -0,0 +1,139 @@
+package aggregate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunWithCSVInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "First column by default",
+			input:      "10.0.0.0/24,web\n10.0.1.0/24,db\n",
+			opts:       Options{InputFormat: inputCSV},
+			wantOutput: "10.0.0.0/23\n",
+		},
+		{
+			name:       "Column by index",
+			input:      "web,192.168.1.0/24\ndb,2001:db8::/32\n",
+			opts:       Options{InputFormat: inputCSV, Column: "2"},
+			wantOutput: "192.168.1.0/24\n2001:db8::/32\n",
+		},
+		{
+			name:       "Column by header name",
+			input:      "Name,Network\nweb,192.168.1.0/24\n",
+			opts:       Options{InputFormat: inputCSV, Column: "network"},
+			wantOutput: "192.168.1.0/24\n",
+		},
+		{
+			name:       "Number selects by index even with a header",
+			input:      "1,2\n10.0.0.0/8,192.168.1.0/24\n",
+			opts:       Options{InputFormat: inputCSV, Column: "2", Header: true},
+			wantOutput: "192.168.1.0/24\n",
+		},
+		{
+			name:       "Numeric header name",
+			input:      "2023,2024,owner\n10.0.0.0/8,192.168.1.0/24,ops\n",
+			opts:       Options{InputFormat: inputCSV, Column: "name:2024", AnnotationColumns: "name:Owner", Format: formatRspamdMap},
+			wantOutput: "192.168.1.0/24 # ops\n",
+		},
+		{
+			name:       "Header skipped with index",
+			input:      "name,network\nweb,192.168.1.0/24\n",
+			opts:       Options{InputFormat: inputCSV, Column: "2", Header: true},
+			wantOutput: "192.168.1.0/24\n",
+		},
+		{
+			name:       "Byte order mark before header",
+			input:      "\ufeffnetwork,name\n10.0.0.0/8,corp\n",
+			opts:       Options{InputFormat: inputCSV, Column: "network"},
+			wantOutput: "10.0.0.0/8\n",
+		},
+		{
+			name:       "Quoted fields",
+			input:      "\"web, eu\",\"10.0.0.0/8\"\n\"db \"\"primary\"\"\",172.16.0.0/12\n",
+			opts:       Options{InputFormat: inputCSV, Column: "2"},
+			wantOutput: "10.0.0.0/8\n172.16.0.0/12\n",
+		},
+		{
+			name:       "Other notations in the field",
+			input:      "a,192.168.1.0-192.168.1.255\nb,10.0.0.*\nc,172.16.0.0 255.255.0.0\n",
+			opts:       Options{InputFormat: inputCSV, Column: "2"},
+			wantOutput: "10.0.0.0/24\n172.16.0.0/16\n192.168.1.0/24\n",
+		},
+		{
+			name:       "TSV",
+			input:      "name\tnetwork\nweb, eu\t10.0.0.0/8\n",
+			opts:       Options{InputFormat: inputTSV, Column: "network"},
+			wantOutput: "10.0.0.0/8\n",
+		},
+		{
+			name:       "Comments and empty fields skipped",
+			input:      "# exported 2024-01-01\nweb,10.0.0.0/8\nspare,\n",
+			opts:       Options{InputFormat: inputCSV, Column: "2"},
+			wantOutput: "10.0.0.0/8\n",
+		},
+		{
+			name:       "Annotation columns",
+			input:      "network,owner,site\n10.0.0.0/24,ops,ams\n10.0.1.0/24,ops,\n",
+			opts:       Options{InputFormat: inputCSV, Column: "network", AnnotationColumns: "owner,3", Format: formatRspamdMap},
+			wantOutput: "10.0.0.0/23 # ops; ams\n",
+		},
+		{
+			name:       "Header only",
+			input:      "network\n",
+			opts:       Options{InputFormat: inputCSV, Column: "network"},
+			wantOutput: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output, errOutput bytes.Buffer
+
+			err := Run(strings.NewReader(tt.input), &output, &errOutput, tt.opts)
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithCSVInputErrors(t *testing.T) {
+	input := "name,network\nweb,10.0.0.0/8\ndb,invalid\nshort\n\"bad\"quote,1.2.3.4\n"
+	var output, errOutput bytes.Buffer
+
+	err := Run(strings.NewReader(input), &output, &errOutput, Options{InputFormat: inputCSV, Column: "network"})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if output.String() != "10.0.0.0/8\n" {
+		t.Errorf("Run() output = %q, want %q", output.String(), "10.0.0.0/8\n")
+	}
+	for _, want := range []string{"line 3:", "line 4: no column 2", "line 5:"} {
+		if !strings.Contains(errOutput.String(), want) {
+			t.Errorf("error output %q does not contain %q", errOutput.String(), want)
+		}
+	}
+}
+
+func TestRunWithCSVInputUnknownColumn(t *testing.T) {
+	var output, errOutput bytes.Buffer
+
+	err := Run(strings.NewReader("name,cidr\nweb,10.0.0.0/8\n"), &output, &errOutput, Options{InputFormat: inputCSV, Column: "network"})
+	if err == nil {
+		t.Fatal("Run() expected error for a missing header column")
+	}
+	if !strings.Contains(errOutput.String(), `column "network" not found`) {
+		t.Errorf("error output = %q", errOutput.String())
+	}
+}
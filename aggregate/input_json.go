@@ -0,0 +1,372 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonReader reads networks from the string fields of a JSON document
+// selected by -path, such as the prefixes of cloud provider range files.
+// Each selected string is parsed like an input line.
+type jsonReader struct{}
+
+// ReadNetworks decodes input and parses the fields selected by every path,
+// keeping those whose record passes the -filter conditions. Fields that
+// fail to parse are reported to errOutput with their location and skipped.
+func (jsonReader) ReadNetworks(input io.Reader, errOutput io.Writer, opts Options) ([]*CIDR, error) {
+	paths, err := parseJSONPaths(opts.JSONPath)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := parseJSONFilters(opts.JSONFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(input)
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after JSON value")
+	}
+
+	var cidrs []*CIDR
+	for _, p := range paths {
+		walkJSON(doc, p, "$", doc, false, func(loc string, record, value any) {
+			for _, f := range filters {
+				if !f.matches(record) {
+					return
+				}
+			}
+			s, ok := value.(string)
+			if !ok {
+				_, _ = fmt.Fprintf(errOutput, "%s: expected a network string, got %s\n", loc, jsonKind(value))
+				return
+			}
+			parsed, err := parseInputAs(s, "")
+			if err != nil {
+				_, _ = fmt.Fprintf(errOutput, "%s: %v\n", loc, err)
+				return
+			}
+			cidrs = append(cidrs, parsed...)
+		})
+	}
+	return cidrs, nil
+}
+
+// defaultJSONPath selects the whole document, a list of networks.
+const defaultJSONPath = "$"
+
+// jsonStep is one step of a path: an object member, an array index, or
+// every element of an array.
+type jsonStep struct {
+	key   string
+	index int
+	array bool // index step; index -1 selects every element
+}
+
+// parseJSONPaths parses a comma-separated list of paths such as
+// "prefixes[].ip_prefix,ipv6_prefixes[].ipv6_prefix". Commas inside quoted
+// member names do not separate paths. An empty list selects the whole
+// document.
+func parseJSONPaths(list string) ([][]jsonStep, error) {
+	if list == "" {
+		list = defaultJSONPath
+	}
+	var paths [][]jsonStep
+	for _, s := range splitUnquoted(list, ",", `"`) {
+		p, err := parseJSONPath(s)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// parseJSONPath parses a path of dotted member names and bracketed array
+// indexes, where [] or [*] selects every element. A leading $ for the
+// document root is optional.
+func parseJSONPath(s string) ([]jsonStep, error) {
+	s = strings.TrimSpace(s)
+	rest := strings.TrimPrefix(s, "$")
+	var steps []jsonStep
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := indexUnquoted(rest, "]", `"`)
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", s)
+			}
+			step, err := parseJSONIndex(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", s, err)
+			}
+			steps = append(steps, step)
+			rest = rest[end+1:]
+		default:
+			if rest[0] == '.' {
+				rest = rest[1:]
+			} else if len(steps) > 0 || len(rest) != len(s) {
+				return nil, fmt.Errorf("invalid path %q: expected . or [ before %q", s, rest)
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty member name", s)
+			}
+			steps = append(steps, jsonStep{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	return steps, nil
+}
+
+// parseJSONIndex parses the contents of a bracketed path step: empty or *
+// for every element, an array index, or a quoted member name.
+func parseJSONIndex(s string) (jsonStep, error) {
+	switch {
+	case s == "" || s == "*":
+		return jsonStep{index: -1, array: true}, nil
+	case strings.HasPrefix(s, `"`):
+		key, err := strconv.Unquote(s)
+		if err != nil {
+			return jsonStep{}, fmt.Errorf("invalid member name %s", s)
+		}
+		return jsonStep{key: key}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return jsonStep{}, fmt.Errorf("invalid array index %q", s)
+	}
+	return jsonStep{index: n, array: true}, nil
+}
+
+// walkJSON calls fn for every value the path selects from v, with its
+// location and its record: the element of the first array the path
+// iterates over, or the document itself when it iterates over none.
+// Arrays at the end of the path are flattened, so a path may name a list of
+// networks. Missing members and indexes select nothing.
+func walkJSON(v any, steps []jsonStep, loc string, record any, inArray bool, fn func(loc string, record, value any)) {
+	if len(steps) == 0 {
+		if elems, ok := v.([]any); ok {
+			for i, e := range elems {
+				walkJSON(e, nil, fmt.Sprintf("%s[%d]", loc, i), record, inArray, fn)
+			}
+			return
+		}
+		if v != nil {
+			fn(loc, record, v)
+		}
+		return
+	}
+
+	step := steps[0]
+	if !step.array {
+		if m, ok := v.(map[string]any); ok {
+			if child, ok := m[step.key]; ok {
+				walkJSON(child, steps[1:], loc+"."+step.key, record, inArray, fn)
+			}
+		}
+		return
+	}
+
+	elems, ok := v.([]any)
+	if !ok {
+		return
+	}
+	for i, e := range elems {
+		if step.index != -1 && step.index != i {
+			continue
+		}
+		rec := record
+		if step.index == -1 && !inArray {
+			rec = e
+		}
+		walkJSON(e, steps[1:], fmt.Sprintf("%s[%d]", loc, i), rec, inArray || step.index == -1, fn)
+	}
+}
+
+// jsonFilter is one -filter condition comparing a field of the record,
+// addressed by a path, with a value that may contain * wildcards.
+type jsonFilter struct {
+	field  []jsonStep
+	value  string
+	negate bool
+}
+
+// parseJSONFilters parses conditions such as `service == "CLOUDFRONT"`
+// joined by &&. Values may be double or single quoted, or bare words, and
+// quoted values may contain && and the comparison operators.
+func parseJSONFilters(s string) ([]jsonFilter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var filters []jsonFilter
+	for _, cond := range splitUnquoted(s, "&&", `"'`) {
+		field, value, negate, err := splitJSONCondition(cond)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := parseJSONPath(field)
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range steps {
+			if step.array && step.index == -1 {
+				return nil, fmt.Errorf("invalid filter %q: fields cannot select every array element", strings.TrimSpace(cond))
+			}
+		}
+		if len(steps) == 0 {
+			return nil, fmt.Errorf("invalid filter %q: missing field", strings.TrimSpace(cond))
+		}
+		filters = append(filters, jsonFilter{field: steps, value: value, negate: negate})
+	}
+	return filters, nil
+}
+
+// splitJSONCondition splits a condition into its field, its unquoted value
+// and whether it uses != rather than ==.
+func splitJSONCondition(cond string) (field, value string, negate bool, err error) {
+	cond = strings.TrimSpace(cond)
+	op := "=="
+	idx := indexUnquoted(cond, op, `"'`)
+	if neq := indexUnquoted(cond, "!=", `"'`); neq != -1 && (idx == -1 || neq < idx) {
+		op, idx, negate = "!=", neq, true
+	}
+	if idx == -1 {
+		return "", "", false, fmt.Errorf("invalid filter %q: expected FIELD == VALUE or FIELD != VALUE", cond)
+	}
+	field = strings.TrimSpace(cond[:idx])
+	value = strings.TrimSpace(cond[idx+len(op):])
+	switch {
+	case len(value) >= 2 && value[0] == '"':
+		if value, err = strconv.Unquote(value); err != nil {
+			return "", "", false, fmt.Errorf("invalid filter %q: malformed quoted value", cond)
+		}
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		value = value[1 : len(value)-1]
+	case value == "":
+		return "", "", false, fmt.Errorf("invalid filter %q: missing value", cond)
+	}
+	return field, value, negate, nil
+}
+
+// matches reports whether the record satisfies the condition. A missing
+// field, or one holding an object or array, equals no value.
+func (f jsonFilter) matches(record any) bool {
+	v := record
+	for _, step := range f.field {
+		if step.array {
+			elems, ok := v.([]any)
+			if !ok || step.index >= len(elems) {
+				return f.negate
+			}
+			v = elems[step.index]
+			continue
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return f.negate
+		}
+		if v, ok = m[step.key]; !ok {
+			return f.negate
+		}
+	}
+
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case bool:
+		s = strconv.FormatBool(v)
+	case nil:
+		s = "null"
+	default:
+		return f.negate
+	}
+	return wildcardMatch(f.value, s) != f.negate
+}
+
+// indexUnquoted returns the index of the first sep in s that is outside
+// quotes, or -1. Any of the quote characters opens a quote closed by the
+// same character; a backslash escapes the next character in double quotes.
+func indexUnquoted(s, sep, quotes string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == 0 && strings.HasPrefix(s[i:], sep):
+			return i
+		case quote == 0 && strings.IndexByte(quotes, c) != -1:
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+	return -1
+}
+
+// splitUnquoted splits s at every sep outside quotes, as indexUnquoted finds
+// them.
+func splitUnquoted(s, sep, quotes string) []string {
+	var parts []string
+	for {
+		idx := indexUnquoted(s, sep, quotes)
+		if idx == -1 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:idx])
+		s = s[idx+len(sep):]
+	}
+}
+
+// wildcardMatch reports whether s matches pattern, in which * matches any
+// run of characters.
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx == -1 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// jsonKind names the JSON type of a decoded value for error messages.
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	default:
+		return "an unexpected value"
+	}
+}
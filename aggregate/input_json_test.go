@@ -0,0 +1,246 @@
+package aggregate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Trimmed copies of the published cloud provider range files.
+const (
+	awsIPRanges = `{
+  "syncToken": "1700000000",
+  "prefixes": [
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "13.32.0.0/16", "region": "GLOBAL", "service": "CLOUDFRONT", "network_border_group": "GLOBAL"},
+    {"ip_prefix": "13.33.0.0/16", "region": "GLOBAL", "service": "CLOUDFRONT", "network_border_group": "GLOBAL"},
+    {"ip_prefix": "52.94.76.0/22", "region": "us-west-2", "service": "AMAZON", "network_border_group": "us-west-2"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:9000::/28", "region": "GLOBAL", "service": "CLOUDFRONT", "network_border_group": "GLOBAL"},
+    {"ipv6_prefix": "2a05:d000::/25", "region": "eu-west-1", "service": "AMAZON", "network_border_group": "eu-west-1"}
+  ]
+}`
+	gcpCloud = `{
+  "syncToken": "1700000000",
+  "prefixes": [
+    {"ipv4Prefix": "34.1.208.0/20", "service": "Google Cloud", "scope": "africa-south1"},
+    {"ipv4Prefix": "34.35.0.0/16", "service": "Google Cloud", "scope": "europe-west1"},
+    {"ipv6Prefix": "2600:1900:4010::/44", "service": "Google Cloud", "scope": "europe-west1"},
+    {"ipv4Prefix": "34.76.0.0/14", "service": "Google Cloud", "scope": "europe-west1"}
+  ]
+}`
+	azureServiceTags = `{
+  "changeNumber": 1,
+  "cloud": "Public",
+  "values": [
+    {"name": "AzureFrontDoor.Frontend", "id": "AzureFrontDoor.Frontend", "properties": {
+      "region": "", "platform": "Azure", "systemService": "AzureFrontDoor",
+      "addressPrefixes": ["13.107.246.0/24", "13.107.213.0/24", "2620:1ec:bdf::/48"]}},
+    {"name": "AzureCloud.westeurope", "id": "AzureCloud.westeurope", "properties": {
+      "region": "westeurope", "platform": "Azure", "systemService": "",
+      "addressPrefixes": ["13.69.0.0/17"]}}
+  ]
+}`
+)
+
+func TestRunWithJSONInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		opts       Options
+		wantOutput string
+	}{
+		{
+			name:       "List of networks by default",
+			input:      `["10.0.0.0/24", "10.0.1.0/24", "2001:db8::1"]`,
+			opts:       Options{InputFormat: inputJSON},
+			wantOutput: "10.0.0.0/23\n2001:db8::1/128\n",
+		},
+		{
+			name:       "AWS CloudFront",
+			input:      awsIPRanges,
+			opts:       Options{InputFormat: inputJSON, JSONPath: "prefixes[].ip_prefix,ipv6_prefixes[].ipv6_prefix", JSONFilter: `service == "CLOUDFRONT"`},
+			wantOutput: "13.32.0.0/15\n2600:9000::/28\n",
+		},
+		{
+			name:       "AWS combined conditions",
+			input:      awsIPRanges,
+			opts:       Options{InputFormat: inputJSON, JSONPath: "$.prefixes[*].ip_prefix", JSONFilter: `service == 'AMAZON' && region != "ap-*"`},
+			wantOutput: "52.94.76.0/22\n",
+		},
+		{
+			name:       "GCP region with wildcard",
+			input:      gcpCloud,
+			opts:       Options{InputFormat: inputJSON, JSONPath: "prefixes[].ipv4Prefix,prefixes[].ipv6Prefix", JSONFilter: "scope == europe-*"},
+			wantOutput: "34.35.0.0/16\n34.76.0.0/14\n2600:1900:4010::/44\n",
+		},
+		{
+			name:       "Azure service tag",
+			input:      azureServiceTags,
+			opts:       Options{InputFormat: inputJSON, JSONPath: "values[].properties.addressPrefixes", JSONFilter: `properties.systemService == "AzureFrontDoor"`},
+			wantOutput: "13.107.213.0/24\n13.107.246.0/24\n2620:1ec:bdf::/48\n",
+		},
+		{
+			name:       "Array index and quoted member",
+			input:      `{"ranges": [{"v4 list": ["10.0.0.0/8"]}, {"v4 list": ["172.16.0.0/12"]}]}`,
+			opts:       Options{InputFormat: inputJSON, JSONPath: `ranges[1]["v4 list"]`},
+			wantOutput: "172.16.0.0/12\n",
+		},
+		{
+			name:       "Comma in quoted member",
+			input:      `{"v4, v6": ["10.0.0.0/8", "2001:db8::/32"], "extra": ["172.16.0.0/12"]}`,
+			opts:       Options{InputFormat: inputJSON, JSONPath: `["v4, v6"],extra`},
+			wantOutput: "10.0.0.0/8\n172.16.0.0/12\n2001:db8::/32\n",
+		},
+		{
+			name:       "Trailing whitespace",
+			input:      "[\"10.0.0.0/8\"]\n\n",
+			opts:       Options{InputFormat: inputJSON},
+			wantOutput: "10.0.0.0/8\n",
+		},
+		{
+			name:       "Other notations in the field",
+			input:      `{"blocked": ["192.168.1.0-192.168.1.255", "10.0.0.*"]}`,
+			opts:       Options{InputFormat: inputJSON, JSONPath: "blocked"},
+			wantOutput: "10.0.0.0/24\n192.168.1.0/24\n",
+		},
+		{
+			name:       "Missing path",
+			input:      awsIPRanges,
+			opts:       Options{InputFormat: inputJSON, JSONPath: "prefixes[].cidr"},
+			wantOutput: "",
+		},
+		{
+			name:       "Empty input",
+			input:      "",
+			opts:       Options{InputFormat: inputJSON},
+			wantOutput: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output, errOutput bytes.Buffer
+
+			err := Run(strings.NewReader(tt.input), &output, &errOutput, tt.opts)
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v (%s)", err, errOutput.String())
+			}
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRunWithJSONInputErrors(t *testing.T) {
+	input := `{"prefixes": [{"ip_prefix": "10.0.0.0/8"}, {"ip_prefix": "bogus"}, {"ip_prefix": 42}]}`
+	var output, errOutput bytes.Buffer
+
+	err := Run(strings.NewReader(input), &output, &errOutput, Options{InputFormat: inputJSON, JSONPath: "prefixes[].ip_prefix"})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if output.String() != "10.0.0.0/8\n" {
+		t.Errorf("Run() output = %q, want %q", output.String(), "10.0.0.0/8\n")
+	}
+	for _, want := range []string{"$.prefixes[1].ip_prefix: invalid CIDR", "$.prefixes[2].ip_prefix: expected a network string, got a number"} {
+		if !strings.Contains(errOutput.String(), want) {
+			t.Errorf("error output %q does not contain %q", errOutput.String(), want)
+		}
+	}
+
+	output.Reset()
+	errOutput.Reset()
+	if err := Run(strings.NewReader(`{"prefixes": [`), &output, &errOutput, Options{InputFormat: inputJSON}); err == nil {
+		t.Error("Run() expected error for malformed JSON")
+	}
+
+	for _, input := range []string{`["10.0.0.0/8"] ["172.16.0.0/12"]`, `["10.0.0.0/8"]]`} {
+		err := Run(strings.NewReader(input), &output, &errOutput, Options{InputFormat: inputJSON})
+		if err == nil || !strings.Contains(err.Error(), "trailing data") {
+			t.Errorf("Run(%q) error = %v, want trailing data error", input, err)
+		}
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []jsonStep
+		wantErr bool
+	}{
+		{path: "$", want: nil},
+		{path: "prefixes[].ip_prefix", want: []jsonStep{{key: "prefixes"}, {index: -1, array: true}, {key: "ip_prefix"}}},
+		{path: "$.values[*].properties", want: []jsonStep{{key: "values"}, {index: -1, array: true}, {key: "properties"}}},
+		{path: `a[2]["b.c"]`, want: []jsonStep{{key: "a"}, {index: 2, array: true}, {key: "b.c"}}},
+		{path: `["a]b"].c`, want: []jsonStep{{key: "a]b"}, {key: "c"}}},
+		{path: "$prefixes", wantErr: true},
+		{path: "a[", wantErr: true},
+		{path: "a[-1]", wantErr: true},
+		{path: "a..b", wantErr: true},
+		{path: "a[0]b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parseJSONPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJSONPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseJSONPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseJSONPath(%q)[%d] = %+v, want %+v", tt.path, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseJSONFilters(t *testing.T) {
+	record := map[string]any{"service": "CLOUDFRONT", "region": "eu-west-1", "tags": []any{"edge"}, "team": "R&&D == ops", "a==b": "x"}
+	tests := []struct {
+		filter    string
+		wantMatch bool
+		wantErr   bool
+	}{
+		{filter: `service == "CLOUDFRONT"`, wantMatch: true},
+		{filter: `service != "CLOUDFRONT"`, wantMatch: false},
+		{filter: `region == 'eu-*'`, wantMatch: true},
+		{filter: `region == *-1 && service == CLOUD*`, wantMatch: true},
+		{filter: `tags[0] == edge`, wantMatch: true},
+		{filter: `team == "R&&D == ops" && service == CLOUDFRONT`, wantMatch: true},
+		{filter: `team != 'R&&D*' && service == CLOUDFRONT`, wantMatch: false},
+		{filter: `["a==b"] == x`, wantMatch: true},
+		{filter: `missing == x`, wantMatch: false},
+		{filter: `missing != x`, wantMatch: true},
+		{filter: `service = "CLOUDFRONT"`, wantErr: true},
+		{filter: `service ==`, wantErr: true},
+		{filter: `== x`, wantErr: true},
+		{filter: `tags[] == edge`, wantErr: true},
+		{filter: `service == "unterminated`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filters, err := parseJSONFilters(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJSONFilters(%q) error = %v, wantErr %v", tt.filter, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			matched := true
+			for _, f := range filters {
+				matched = matched && f.matches(record)
+			}
+			if matched != tt.wantMatch {
+				t.Errorf("parseJSONFilters(%q) match = %v, want %v", tt.filter, matched, tt.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package aggregate
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Options holds the settings that control how networks are processed and
+// written, one field per command-line flag. The zero value reproduces the
+// default behaviour: plain aggregation with one CIDR per line.
+type Options struct {
+	// MaxPrefix4 and MaxPrefix6 cap the prefix length of input entries.
+	// Entries more specific than the cap are widened to their covering
+	// prefix (or dropped, see DropLonger) before aggregation. Zero disables.
+	MaxPrefix4 int
+	MaxPrefix6 int
+	DropLonger bool
+
+	// MinPrefix4 and MinPrefix6 split aggregated networks broader than the
+	// given length into subnets of that length. Zero disables.
+	MinPrefix4 int
+	MinPrefix6 int
+
+	// ChunkSize partitions the output into groups of at most this many
+	// entries per address family. Zero disables chunking.
+	ChunkSize       int
+	ChunkPrefix     string // write chunks to numbered files with this prefix
+	ChunkContiguous bool   // also break chunks at gaps in address space
+
+	// chunk numbers the chunk being written, from 1, and chunkStart counts
+	// the entries of its family written in earlier chunks. They are set by
+	// writeChunks, not by flags, and are zero when output is not chunked.
+	chunk      int
+	chunkStart int
+
+	// Format selects the output format; empty means cidr. InputFormat
+	// forces the notation of input lines; empty detects it per line.
+	Format      string
+	InputFormat string
+
+	// Column picks the network field of CSV and TSV input and
+	// AnnotationColumns the comma-separated fields annotating it, each by
+	// 1-based index or header name; name: before a number selects a
+	// numeric header. Header treats the first record as a header even when
+	// all columns are given by index.
+	Column            string
+	AnnotationColumns string
+	Header            bool
+
+	// JSONPath selects the network fields of JSON input and JSONFilter
+	// the records they are taken from, in the syntax of -path and -filter.
+	JSONPath   string
+	JSONFilter string
+
+	// Name and Action label generated configuration such as prefix-lists
+	// and ACLs; empty values fall back to "aggregated" and "permit".
+	Name   string
+	Action string
+
+	// GE and LE bound the matched prefix lengths of prefix-list entries.
+	// Zero omits the bound.
+	GE int
+	LE int
+
+	// NextHop4 and NextHop6 route announced networks via a gateway;
+	// empty values produce blackhole routes. Community is attached to
+	// announced routes in ASN:VALUE form.
+	NextHop4  string
+	NextHop6  string
+	Community string
+
+	// Diff names a previously announced list; only the announce/withdraw
+	// changes relative to it are written (exabgp and gobgp formats).
+	Diff string
+
+	// Comment and Timeout are attached to every generated entry by formats
+	// that support them, such as RouterOS address-lists. Without a comment,
+	// entries of the threat-intel and later formats carry the annotations
+	// of the input lines they came from.
+	Comment string
+	Timeout string
+
+	// RuleEntries overrides the provider limit on entries per cloud or
+	// Windows firewall rule or object. Zero uses the provider default.
+	RuleEntries int
+
+	// Exclude names a list of networks to leave out of a NetworkPolicy.
+	Exclude string
+
+	// Value is paired with each network by key/value map formats; empty
+	// uses the format's default.
+	Value string
+
+	// Source names the feed in threat-intel formats; empty uses
+	// "aggregate-cidr". Category and Score are the Suricata IP reputation
+	// category ID and score; zero uses the defaults.
+	Source   string
+	Category int
+	Score    int
+
+	// SQLAnnotation adds a column holding each entry's comment to SQL
+	// output; a source column is added when Source is set.
+	SQLAnnotation bool
+
+	// GoPackage names the package of generated Go source; empty uses
+	// "main".
+	GoPackage string
+
+	// Template names the text/template file used by the template format.
+	Template string
+}
+
+// defaultListName names generated lists when -name is not given.
+const defaultListName = "aggregated"
+
+// ListName returns the configured list name or the default.
+func (o *Options) ListName() string {
+	if o.Name == "" {
+		return defaultListName
+	}
+	return o.Name
+}
+
+// defaultSource names the feed in threat-intel formats when -source is
+// not given.
+const defaultSource = "aggregate-cidr"
+
+// FeedSource returns the configured feed name or the default.
+func (o *Options) FeedSource() string {
+	if o.Source == "" {
+		return defaultSource
+	}
+	return o.Source
+}
+
+// NextHopFor returns the configured next-hop for the network's family.
+func (o *Options) NextHopFor(c *CIDR) string {
+	if c.bits == 32 {
+		return o.NextHop4
+	}
+	return o.NextHop6
+}
+
+// entryLimit returns the configured entries-per-rule limit or the
+// provider default.
+func (o *Options) entryLimit(providerDefault int) int {
+	if o.RuleEntries == 0 {
+		return providerDefault
+	}
+	return o.RuleEntries
+}
+
+// MapValue returns the configured map value or the format default.
+func (o *Options) MapValue(formatDefault string) string {
+	if o.Value == "" {
+		return formatDefault
+	}
+	return o.Value
+}
+
+// EntryComment returns the configured comment, or else the annotations of
+// the network joined into one line.
+func (o *Options) EntryComment(c *CIDR) string {
+	if o.Comment != "" {
+		return o.Comment
+	}
+	comment := strings.Join(c.annotations, "; ")
+	if c.omittedAnnotations > 0 {
+		comment += fmt.Sprintf("; and %d more", c.omittedAnnotations)
+	}
+	return comment
+}
+
+// keepsAnnotations reports whether the selected output format writes the
+// annotations of input lines, so they are only collected when needed.
+func (o *Options) keepsAnnotations() bool {
+	format, err := outputFormatFor(o.Format)
+	return err == nil && format.annotated
+}
+
+// Chunk returns the number of the chunk being written, from 1, and the
+// number of entries of its family written in earlier chunks, so writers
+// can keep names and sequence numbers unique across chunks. Both are zero
+// when output is not chunked.
+func (o *Options) Chunk() (n, start int) {
+	return o.chunk, o.chunkStart
+}
+
+// RuleAction returns the configured permit/deny action or "permit".
+func (o *Options) RuleAction() string {
+	if o.Action == "" {
+		return "permit"
+	}
+	return o.Action
+}
+
+// ParseFlags parses the aggregate-cidr command-line arguments into Options.
+// It returns the remaining positional arguments (the optional input file).
+func ParseFlags(args []string, errOutput io.Writer) (Options, []string, error) {
+	var opts Options
+
+	fs := flag.NewFlagSet("aggregate-cidr", flag.ContinueOnError)
+	fs.SetOutput(errOutput)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(errOutput, "Usage: aggregate-cidr [options] [file]\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.IntVar(&opts.MaxPrefix4, "max-prefix4", 0, "widen IPv4 entries longer than `N` bits to their covering /N (0 disables)")
+	fs.IntVar(&opts.MaxPrefix6, "max-prefix6", 0, "widen IPv6 entries longer than `N` bits to their covering /N (0 disables)")
+	fs.BoolVar(&opts.DropLonger, "drop-longer", false, "drop entries longer than -max-prefix4/-max-prefix6 instead of widening them")
+	fs.IntVar(&opts.MinPrefix4, "min-prefix4", 0, "split IPv4 networks shorter than `N` bits into /N subnets after aggregation (0 disables)")
+	fs.IntVar(&opts.MinPrefix6, "min-prefix6", 0, "split IPv6 networks shorter than `N` bits into /N subnets after aggregation (0 disables)")
+	fs.IntVar(&opts.ChunkSize, "chunk-size", 0, "partition output into groups of at most `N` entries per address family (0 disables; not for document formats)")
+	fs.StringVar(&opts.ChunkPrefix, "chunk-prefix", "", "write chunks to numbered files `PREFIX`-ipv4-001.txt, ... (extension by format) instead of labelled sections")
+	fs.BoolVar(&opts.ChunkContiguous, "chunk-contiguous", false, "start a new chunk at every gap so each chunk covers one contiguous range")
+	fs.StringVar(&opts.Format, "format", "", "output `FORMAT`, one of "+strings.Join(outputs.names, ", ")+" (default cidr)")
+	fs.StringVar(&opts.Format, "output-format", "", "alias for -format")
+	fs.StringVar(&opts.InputFormat, "input-format", "", "parse input as `FORMAT`, one of "+strings.Join(inputFormatNames(), ", ")+" (default: detect per line)")
+	fs.StringVar(&opts.Column, "column", "", "network `COLUMN` of csv and tsv input, as a 1-based index or header name, with name: before numeric header names (default "+defaultColumn+")")
+	fs.StringVar(&opts.AnnotationColumns, "annotation-columns", "", "comma-separated `COLUMNS` of csv and tsv input to annotate each network with")
+	fs.StringVar(&opts.JSONPath, "path", "", "comma-separated `PATHS` to the network fields of json input, such as prefixes[].ip_prefix (default $, a list of networks)")
+	fs.StringVar(&opts.JSONFilter, "filter", "", "take json networks only from records matching `CONDITIONS` such as 'service == \"CLOUDFRONT\"', joined by &&; values may use * wildcards")
+	fs.BoolVar(&opts.Header, "header", false, "skip the first record of csv and tsv input as a header (implied when a column is given by name)")
+	fs.StringVar(&opts.Name, "name", "", "`NAME` of the generated list, ACL or set (default "+defaultListName+")")
+	fs.StringVar(&opts.Action, "action", "", "rule `ACTION` for generated configuration: permit or deny (default permit)")
+	fs.IntVar(&opts.GE, "ge", 0, "add \"ge `N`\" to prefix-list entries shorter than N (0 omits)")
+	fs.IntVar(&opts.LE, "le", 0, "add \"le `N`\" to prefix-list entries shorter than N (0 omits)")
+	fs.StringVar(&opts.NextHop4, "next-hop4", "", "route IPv4 networks via `ADDR` instead of blackholing them")
+	fs.StringVar(&opts.NextHop6, "next-hop6", "", "route IPv6 networks via `ADDR` instead of blackholing them")
+	fs.StringVar(&opts.Community, "community", "", "BGP `COMMUNITY` (ASN:VALUE) to attach to announced routes, e.g. 65535:666")
+	fs.StringVar(&opts.Diff, "diff", "", "write only announce/withdraw changes relative to the previous list in `FILE` (exabgp and gobgp formats)")
+	fs.StringVar(&opts.Comment, "comment", "", "`TEXT` to attach as a comment to each generated entry (mikrotik, pfsense-alias and cloud formats; threat-intel, DNSBL and mail formats default to the input line comments)")
+	fs.StringVar(&opts.Timeout, "timeout", "", "entry lifetime as a RouterOS `DURATION` such as 1d or 12h (mikrotik)")
+	fs.IntVar(&opts.RuleEntries, "rule-entries", 0, "split cloud and Windows firewall rules at `N` entries instead of the provider limit (0 uses the limit)")
+	fs.StringVar(&opts.Exclude, "exclude", "", "list the networks in `FILE` as except entries of k8s-networkpolicy ipBlocks (with -action deny, egress to them stays allowed)")
+	fs.StringVar(&opts.Value, "value", "", "`VALUE` paired with each network by map formats such as nginx-geo and haproxy-map (default 1), the rbldnsd A record (default 127.0.0.2), the rpz record data (default \"CNAME .\"), the postfix-cidr action (default OK, or REJECT with -action deny) or the bpftool-lpm map value in hex (default \""+defaultBPFValue+"\")")
+	fs.StringVar(&opts.Source, "source", "", "`NAME` of the feed recorded by threat-intel formats (default "+defaultSource+"), and in a source column of SQL output")
+	fs.IntVar(&opts.Category, "category", 0, "Suricata IP reputation category `ID` (default 1)")
+	fs.IntVar(&opts.Score, "score", 0, "Suricata IP reputation score `N` between 1 and 127 (default 127)")
+	fs.BoolVar(&opts.SQLAnnotation, "sql-annotation", false, "add an annotation column holding each entry's comment to SQL output (postgres-copy, postgres-insert, sql-range)")
+
+	fs.StringVar(&opts.GoPackage, "package", "", "Go `PACKAGE` name for generated source (go, go-ranges; default "+defaultGoPackage+")")
+
+	fs.StringVar(&opts.Template, "template", "", "text/template `FILE` to render the networks with (template format)")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		_, _ = fmt.Fprintf(errOutput, "error: %v\n", err)
+		return opts, nil, err
+	}
+	return opts, fs.Args(), nil
+}
+
+// Validate checks that option values are within range for their address
+// family and that the options given work together.
+func (o *Options) Validate() error {
+	if o.MaxPrefix4 < 0 || o.MaxPrefix4 > 32 {
+		return fmt.Errorf("-max-prefix4 must be between 0 and 32, got %d", o.MaxPrefix4)
+	}
+	if o.MaxPrefix6 < 0 || o.MaxPrefix6 > 128 {
+		return fmt.Errorf("-max-prefix6 must be between 0 and 128, got %d", o.MaxPrefix6)
+	}
+	if o.MinPrefix4 < 0 || o.MinPrefix4 > 32 {
+		return fmt.Errorf("-min-prefix4 must be between 0 and 32, got %d", o.MinPrefix4)
+	}
+	if o.MinPrefix6 < 0 || o.MinPrefix6 > 128 {
+		return fmt.Errorf("-min-prefix6 must be between 0 and 128, got %d", o.MinPrefix6)
+	}
+	if o.MaxPrefix4 != 0 && o.MinPrefix4 > o.MaxPrefix4 {
+		return fmt.Errorf("-min-prefix4 (%d) cannot exceed -max-prefix4 (%d)", o.MinPrefix4, o.MaxPrefix4)
+	}
+	if o.MaxPrefix6 != 0 && o.MinPrefix6 > o.MaxPrefix6 {
+		return fmt.Errorf("-min-prefix6 (%d) cannot exceed -max-prefix6 (%d)", o.MinPrefix6, o.MaxPrefix6)
+	}
+	if o.ChunkSize < 0 {
+		return fmt.Errorf("-chunk-size must not be negative, got %d", o.ChunkSize)
+	}
+	if o.ChunkSize == 0 && (o.ChunkPrefix != "" || o.ChunkContiguous) {
+		return fmt.Errorf("-chunk-prefix and -chunk-contiguous require -chunk-size")
+	}
+	if o.Action != "" && o.Action != "permit" && o.Action != "deny" {
+		return fmt.Errorf("-action must be permit or deny, got %q", o.Action)
+	}
+	if o.GE < 0 || o.GE > 128 {
+		return fmt.Errorf("-ge must be between 0 and 128, got %d", o.GE)
+	}
+	if o.LE < 0 || o.LE > 128 {
+		return fmt.Errorf("-le must be between 0 and 128, got %d", o.LE)
+	}
+	if o.GE != 0 && o.LE != 0 && o.GE > o.LE {
+		return fmt.Errorf("-ge (%d) cannot exceed -le (%d)", o.GE, o.LE)
+	}
+	if o.NextHop4 != "" {
+		if ip := net.ParseIP(o.NextHop4); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("-next-hop4 must be an IPv4 address, got %q", o.NextHop4)
+		}
+	}
+	if o.NextHop6 != "" {
+		if ip := net.ParseIP(o.NextHop6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("-next-hop6 must be an IPv6 address, got %q", o.NextHop6)
+		}
+	}
+	if o.Community != "" && !isValidCommunity(o.Community) {
+		return fmt.Errorf("-community must be in ASN:VALUE form with 16-bit parts, got %q", o.Community)
+	}
+	if o.Diff != "" && o.Format != formatExaBGP && o.Format != formatGoBGP {
+		return fmt.Errorf("-diff requires -format %s or %s", formatExaBGP, formatGoBGP)
+	}
+	if o.Diff != "" && o.ChunkSize > 0 {
+		return fmt.Errorf("-diff cannot be combined with -chunk-size")
+	}
+	if o.Timeout != "" && !routerOSTimeout.MatchString(o.Timeout) {
+		return fmt.Errorf("-timeout must be a duration such as 1d, 12h or 1w2d, got %q", o.Timeout)
+	}
+	if o.RuleEntries < 0 {
+		return fmt.Errorf("-rule-entries must not be negative, got %d", o.RuleEntries)
+	}
+	if o.Category < 0 || o.Category > 60 {
+		return fmt.Errorf("-category must be between 1 and 60, got %d", o.Category)
+	}
+	if o.Score < 0 || o.Score > 127 {
+		return fmt.Errorf("-score must be between 1 and 127, got %d", o.Score)
+	}
+	if o.Value != "" && o.Format == formatRBLDNSD {
+		if ip := net.ParseIP(o.Value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("-value for -format %s must be an IPv4 address, got %q", formatRBLDNSD, o.Value)
+		}
+	}
+	if o.Value != "" && o.Format == formatBPFToolLPM && !bpfHexBytes.MatchString(o.Value) {
+		return fmt.Errorf("-value for -format %s must be space-separated hex bytes such as \"01 00 00 00\", got %q", formatBPFToolLPM, o.Value)
+	}
+	if (o.Format == formatTemplate) != (o.Template != "") {
+		return fmt.Errorf("-format %s and -template must be given together", formatTemplate)
+	}
+	if o.GoPackage != "" && !token.IsIdentifier(o.GoPackage) {
+		return fmt.Errorf("-package must be a Go identifier, got %q", o.GoPackage)
+	}
+	if o.Action == "deny" && (o.Format == formatAWSSecurityGroup || o.Format == formatAWSPrefixList) {
+		return fmt.Errorf("-format %s only supports allow rules, not -action deny", o.Format)
+	}
+	if o.Exclude != "" && o.Format != formatNetworkPolicy {
+		return fmt.Errorf("-exclude requires -format %s", formatNetworkPolicy)
+	}
+	if o.Format == formatGCPFirewall && !gcpRuleName.MatchString(o.ListName()+"-ipv4-1") {
+		return fmt.Errorf("-name for -format %s must start with a lowercase letter and hold only lowercase letters, digits and hyphens, got %q", formatGCPFirewall, o.Name)
+	}
+	if o.Format == formatBPFToolLPM && !bpfMapName.MatchString(o.ListName()+"_v4") {
+		return fmt.Errorf("-name for -format %s must be at most 12 letters, digits, underscores and dots, got %q", formatBPFToolLPM, o.Name)
+	}
+	if o.Format == formatPFSenseAlias && !pfSenseAliasName.MatchString(o.ListName()) {
+		return fmt.Errorf("-name for -format %s must be at most 31 letters, digits and underscores, got %q", formatPFSenseAlias, o.Name)
+	}
+	if (o.Column != "" || o.AnnotationColumns != "" || o.Header) && o.InputFormat != inputCSV && o.InputFormat != inputTSV {
+		return fmt.Errorf("-column, -annotation-columns and -header require -input-format %s or %s", inputCSV, inputTSV)
+	}
+	columns := csvColumnSpecs(o.AnnotationColumns)
+	if o.Column != "" {
+		columns = append(columns, o.Column)
+	}
+	for _, spec := range columns {
+		if n, ok := csvIndex(spec); spec == "" || spec == csvNamePrefix || (ok && n < 0) {
+			return fmt.Errorf("columns must be 1-based indexes or header names, got %q", spec)
+		}
+	}
+	if (o.JSONPath != "" || o.JSONFilter != "") && o.InputFormat != inputJSON {
+		return fmt.Errorf("-path and -filter require -input-format %s", inputJSON)
+	}
+	if _, err := parseJSONPaths(o.JSONPath); err != nil {
+		return fmt.Errorf("-path: %v", err)
+	}
+	if _, err := parseJSONFilters(o.JSONFilter); err != nil {
+		return fmt.Errorf("-filter: %v", err)
+	}
+	format, err := outputFormatFor(o.Format)
+	if err != nil {
+		return err
+	}
+	if o.ChunkSize > 0 && format.chunks == nil {
+		return fmt.Errorf("-format %s cannot be split with -chunk-size", o.Format)
+	}
+	return validateInputFormat(o.InputFormat)
+}
+
+// isValidCommunity reports whether s is a standard BGP community,
+// two decimal 16-bit values separated by a colon.
+func isValidCommunity(s string) bool {
+	asn, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return false
+	}
+	for _, part := range []string{asn, value} {
+		if _, err := strconv.ParseUint(part, 10, 16); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     Options
+		wantArgs []string
+		wantErr  bool
+	}{
+		{name: "No arguments", args: nil, want: Options{}},
+		{name: "File only", args: []string{"list.txt"}, want: Options{}, wantArgs: []string{"list.txt"}},
+		{
+			name:     "Prefix caps",
+			args:     []string{"-max-prefix4", "24", "-max-prefix6", "64", "list.txt"},
+			want:     Options{MaxPrefix4: 24, MaxPrefix6: 64},
+			wantArgs: []string{"list.txt"},
+		},
+		{
+			name: "Drop longer",
+			args: []string{"-max-prefix4=24", "-drop-longer"},
+			want: Options{MaxPrefix4: 24, DropLonger: true},
+		},
+		{
+			name: "Split lengths",
+			args: []string{"-min-prefix4", "16", "-min-prefix6", "32"},
+			want: Options{MinPrefix4: 16, MinPrefix6: 32},
+		},
+		{
+			name: "Split and cap together",
+			args: []string{"-min-prefix4", "16", "-max-prefix4", "24"},
+			want: Options{MinPrefix4: 16, MaxPrefix4: 24},
+		},
+		{
+			name: "Chunk files",
+			args: []string{"-chunk-size", "1000", "-chunk-prefix", "out/sg", "-chunk-contiguous"},
+			want: Options{ChunkSize: 1000, ChunkPrefix: "out/sg", ChunkContiguous: true},
+		},
+		{name: "Range format", args: []string{"-format", "range"}, want: Options{Format: formatRange}},
+		{
+			name: "Prefix-list settings",
+			args: []string{"-format", "cisco-prefix-list", "-name", "BOGONS", "-action", "deny", "-ge", "16", "-le", "24"},
+			want: Options{Format: formatCiscoPrefixList, Name: "BOGONS", Action: "deny", GE: 16, LE: 24},
+		},
+		{
+			name: "Routing settings",
+			args: []string{"-next-hop4", "192.0.2.1", "-next-hop6", "2001:db8::1", "-community", "65535:666"},
+			want: Options{NextHop4: "192.0.2.1", NextHop6: "2001:db8::1", Community: "65535:666"},
+		},
+		{
+			name: "Diff mode",
+			args: []string{"-format", "exabgp", "-diff", "previous.txt"},
+			want: Options{Format: formatExaBGP, Diff: "previous.txt"},
+		},
+		{
+			name: "Entry comment and timeout",
+			args: []string{"-comment", "drop list", "-timeout", "1w2d"},
+			want: Options{Comment: "drop list", Timeout: "1w2d"},
+		},
+		{
+			name: "Cloud rule entries",
+			args: []string{"-format", "azure-nsg", "-action", "deny", "-rule-entries", "500"},
+			want: Options{Format: formatAzureNSG, Action: "deny", RuleEntries: 500},
+		},
+		{
+			name: "Exclusions",
+			args: []string{"-format", "k8s-networkpolicy", "-exclude", "ours.txt"},
+			want: Options{Format: formatNetworkPolicy, Exclude: "ours.txt"},
+		},
+		{
+			name: "Map value",
+			args: []string{"-format", "haproxy-map", "-value", "blocked"},
+			want: Options{Format: formatHAProxyMap, Value: "blocked"},
+		},
+		{
+			name: "Threat-intel settings",
+			args: []string{"-format", "suricata-iprep", "-source", "spamhaus", "-category", "3", "-score", "90"},
+			want: Options{Format: formatSuricataIPRep, Source: "spamhaus", Category: 3, Score: 90},
+		},
+		{
+			name: "SQL columns",
+			args: []string{"-format", "postgres-copy", "-name", "intel.blocklist", "-source", "feed", "-sql-annotation"},
+			want: Options{Format: formatPostgresCopy, Name: "intel.blocklist", Source: "feed", SQLAnnotation: true},
+		},
+		{
+			name: "Go package",
+			args: []string{"-format", "go", "-package", "acl"},
+			want: Options{Format: formatGoSource, GoPackage: "acl"},
+		},
+		{
+			name: "Template",
+			args: []string{"-format", "template", "-template", "list.tmpl"},
+			want: Options{Format: formatTemplate, Template: "list.tmpl"},
+		},
+		{
+			name: "Output format alias",
+			args: []string{"-output-format", "range"},
+			want: Options{Format: formatRange},
+		},
+		{
+			name: "Input format",
+			args: []string{"-input-format", "netmask"},
+			want: Options{InputFormat: inputNetmask},
+		},
+		{
+			name: "CSV columns",
+			args: []string{"-input-format", "csv", "-column", "network", "-annotation-columns", "2,owner", "-header"},
+			want: Options{InputFormat: inputCSV, Column: "network", AnnotationColumns: "2,owner", Header: true},
+		},
+		{
+			name: "JSON path and filter",
+			args: []string{"-input-format", "json", "-path", "prefixes[].ip_prefix", "-filter", `service == "CLOUDFRONT"`},
+			want: Options{InputFormat: inputJSON, JSONPath: "prefixes[].ip_prefix", JSONFilter: `service == "CLOUDFRONT"`},
+		},
+
+		// Invalid values
+		{name: "IPv4 cap too large", args: []string{"-max-prefix4", "33"}, wantErr: true},
+		{name: "IPv6 cap too large", args: []string{"-max-prefix6", "129"}, wantErr: true},
+		{name: "Negative cap", args: []string{"-max-prefix4", "-1"}, wantErr: true},
+		{name: "Split length too large", args: []string{"-min-prefix4", "33"}, wantErr: true},
+		{name: "Split longer than cap", args: []string{"-min-prefix6", "64", "-max-prefix6", "48"}, wantErr: true},
+		{name: "Negative chunk size", args: []string{"-chunk-size", "-5"}, wantErr: true},
+		{name: "Chunk prefix without size", args: []string{"-chunk-prefix", "out"}, wantErr: true},
+		{name: "Chunked document format", args: []string{"-format", "aws-sg", "-chunk-size", "60"}, wantErr: true},
+		{name: "Unknown format", args: []string{"-format", "bogus"}, wantErr: true},
+		{name: "Unknown action", args: []string{"-action", "reject"}, wantErr: true},
+		{name: "ge above le", args: []string{"-ge", "24", "-le", "16"}, wantErr: true},
+		{name: "le too large", args: []string{"-le", "129"}, wantErr: true},
+		{name: "IPv6 next-hop4", args: []string{"-next-hop4", "2001:db8::1"}, wantErr: true},
+		{name: "IPv4 next-hop6", args: []string{"-next-hop6", "192.0.2.1"}, wantErr: true},
+		{name: "Invalid next-hop", args: []string{"-next-hop4", "gateway"}, wantErr: true},
+		{name: "Community without colon", args: []string{"-community", "65535"}, wantErr: true},
+		{name: "Community out of range", args: []string{"-community", "65536:1"}, wantErr: true},
+		{name: "Diff with line format", args: []string{"-diff", "previous.txt"}, wantErr: true},
+		{name: "Diff with chunks", args: []string{"-format", "exabgp", "-diff", "previous.txt", "-chunk-size", "10"}, wantErr: true},
+		{name: "Invalid timeout", args: []string{"-timeout", "tomorrow"}, wantErr: true},
+		{name: "Negative rule entries", args: []string{"-rule-entries", "-1"}, wantErr: true},
+		{name: "Exclusions with line format", args: []string{"-exclude", "ours.txt"}, wantErr: true},
+		{name: "AWS deny", args: []string{"-format", "aws-sg", "-action", "deny"}, wantErr: true},
+		{name: "GCP name with capitals", args: []string{"-format", "gcp-firewall", "-name", "Office"}, wantErr: true},
+		{name: "bpftool map name too long", args: []string{"-format", "bpftool-lpm", "-name", "blocked_hosts"}, wantErr: true},
+		{name: "bpftool map name with hyphen", args: []string{"-format", "bpftool-lpm", "-name", "blocked-ips"}, wantErr: true},
+		{name: "pfSense alias name with hyphen", args: []string{"-format", "pfsense-alias", "-name", "office-nets"}, wantErr: true},
+		{name: "pfSense alias name too long", args: []string{"-format", "pfsense-alias", "-name", "office_networks_blocked_by_policy"}, wantErr: true},
+		{name: "GCP name too long", args: []string{"-format", "gcp-firewall", "-name", "office-network-blocklist-managed-by-the-platform-security-team"}, wantErr: true},
+		{name: "Category too large", args: []string{"-category", "61"}, wantErr: true},
+		{name: "Score too large", args: []string{"-score", "128"}, wantErr: true},
+		{name: "rbldnsd value not an address", args: []string{"-format", "rbldnsd", "-value", "listed"}, wantErr: true},
+		{name: "Invalid Go package", args: []string{"-format", "go", "-package", "my-acl"}, wantErr: true},
+		{name: "bpftool value not hex", args: []string{"-format", "bpftool-lpm", "-value", "1"}, wantErr: true},
+		{name: "Template format without file", args: []string{"-format", "template"}, wantErr: true},
+		{name: "Template file without format", args: []string{"-template", "list.tmpl"}, wantErr: true},
+		{name: "Unknown input format", args: []string{"-input-format", "bogus"}, wantErr: true},
+		{name: "Column without CSV input", args: []string{"-column", "2"}, wantErr: true},
+		{name: "Column index zero", args: []string{"-input-format", "tsv", "-column", "0"}, wantErr: true},
+		{name: "Column name prefix without a name", args: []string{"-input-format", "csv", "-column", "name:"}, wantErr: true},
+		{name: "Path without JSON input", args: []string{"-path", "prefixes[].ip_prefix"}, wantErr: true},
+		{name: "Invalid JSON path", args: []string{"-input-format", "json", "-path", "prefixes[x]"}, wantErr: true},
+		{name: "Invalid JSON filter", args: []string{"-input-format", "json", "-filter", "service"}, wantErr: true},
+		{name: "Empty annotation column", args: []string{"-input-format", "csv", "-annotation-columns", "2,,3"}, wantErr: true},
+		{name: "Unknown flag", args: []string{"-bogus"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errOutput bytes.Buffer
+
+			got, args, err := ParseFlags(tt.args, &errOutput)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseFlags(%q) expected error, got nil", tt.args)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ParseFlags(%q) unexpected error: %v", tt.args, err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseFlags(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+
+			if len(args) != len(tt.wantArgs) {
+				t.Errorf("ParseFlags(%q) args = %q, want %q", tt.args, args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseFlagsHelp(t *testing.T) {
+	var errOutput bytes.Buffer
+
+	_, _, err := ParseFlags([]string{"-h"}, &errOutput)
+
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("ParseFlags(-h) error = %v, want flag.ErrHelp", err)
+	}
+	if !bytes.Contains(errOutput.Bytes(), []byte("Usage:")) {
+		t.Errorf("ParseFlags(-h) did not print usage, got %q", errOutput.String())
+	}
+}
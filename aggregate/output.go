@@ -0,0 +1,261 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+)
+
+// Output formats selected with -format.
+const (
+	formatCIDR     = "cidr"
+	formatRange    = "range"
+	formatNetmask  = "netmask"
+	formatWildcard = "wildcard"
+
+	formatCiscoPrefixList = "cisco-prefix-list"
+	formatCiscoACL        = "cisco-acl"
+	formatNXOSACL         = "nxos-acl"
+
+	formatJunosPrefixList = "junos-prefix-list"
+	formatJunosConfig     = "junos-config"
+	formatJunosFilter     = "junos-filter"
+
+	formatBIRDPrefixSet = "bird-prefix-set"
+	formatBIRDStatic    = "bird-static"
+	formatFRRPrefixList = "frr-prefix-list"
+	formatFRRStatic     = "frr-static"
+
+	formatExaBGP = "exabgp"
+	formatGoBGP  = "gobgp"
+
+	formatMikroTik = "mikrotik"
+
+	formatPFTable      = "pf-table"
+	formatPFSenseAlias = "pfsense-alias"
+
+	formatAWSSecurityGroup = "aws-sg"
+	formatAWSPrefixList    = "aws-prefix-list"
+	formatGCPFirewall      = "gcp-firewall"
+	formatAzureNSG         = "azure-nsg"
+
+	formatNetworkPolicy    = "k8s-networkpolicy"
+	formatGlobalNetworkSet = "calico-globalnetworkset"
+
+	formatNginx    = "nginx"
+	formatNginxGeo = "nginx-geo"
+	formatApache   = "apache"
+	// HAProxy ACL files (acl NAME src -f FILE) hold one network per line,
+	// which is the plain CIDR output.
+	formatHAProxyACL = "haproxy-acl"
+	formatHAProxyMap = "haproxy-map"
+	formatSquidACL   = "squid-acl"
+
+	formatZeekIntel     = "zeek-intel"
+	formatSuricataIPRep = "suricata-iprep"
+	formatSTIX          = "stix"
+
+	formatRBLDNSD = "rbldnsd"
+	formatRPZ     = "rpz"
+
+	formatPostfixCIDR   = "postfix-cidr"
+	formatRspamdMap     = "rspamd-map"
+	formatEximIPLsearch = "exim-iplsearch"
+
+	formatPostgresCopy   = "postgres-copy"
+	formatPostgresInsert = "postgres-insert"
+	formatSQLRange       = "sql-range"
+
+	formatGoSource = "go"
+	formatGoRanges = "go-ranges"
+	formatCHeader  = "c-header"
+
+	formatIPBatch    = "ip-batch"
+	formatBPFToolLPM = "bpftool-lpm"
+
+	formatPowerShell = "powershell"
+
+	formatTemplate = "template"
+)
+
+// outputFormat is a registered output format: its writer, for formats that
+// can be split with -chunk-size how their chunks are written, and whether
+// its entries carry the annotations of their input lines.
+type outputFormat struct {
+	writer    OutputWriter
+	chunks    *chunkStyle // nil when the format cannot be chunked
+	annotated bool
+}
+
+// annotatedFormats are the built-in formats writing the annotations of input
+// lines, through EntryComment or, for templates, the Annotations field.
+var annotatedFormats = map[string]bool{
+	formatZeekIntel:      true,
+	formatSTIX:           true,
+	formatRBLDNSD:        true,
+	formatRPZ:            true,
+	formatRspamdMap:      true,
+	formatEximIPLsearch:  true,
+	formatPostgresCopy:   true,
+	formatPostgresInsert: true,
+	formatSQLRange:       true,
+	formatTemplate:       true,
+}
+
+// outputs is the output format registry, holding the built-in formats in
+// the order they are listed in help text.
+var outputs = builtinOutputs()
+
+// builtinOutputs registers the built-in output formats. Formats that wrap
+// their entries in a single document, or whose entries depend on each
+// other, are not chunkable.
+func builtinOutputs() *registry[outputFormat] {
+	r := newRegistry[outputFormat]("output")
+	for _, f := range []struct {
+		name   string
+		writer OutputWriter
+		chunks *chunkStyle
+	}{
+		{formatCIDR, FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, _ Options) error { return writeCIDRs(w, cidrs) }), chunks("#", ".txt")},
+		{formatRange, FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, _ Options) error { return writeRanges(w, cidrs) }), chunks("#", ".txt")},
+		{formatNetmask, FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, _ Options) error {
+			return writeMasks(w, cidrs, (*CIDR).Netmask)
+		}), chunks("#", ".txt")},
+		{formatWildcard, FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, _ Options) error {
+			return writeMasks(w, cidrs, (*CIDR).Wildcard)
+		}), chunks("#", ".txt")},
+		{formatCiscoPrefixList, FamilyWriterFunc(writeCiscoPrefixList), chunks("!", ".cfg")},
+		{formatCiscoACL, FamilyWriterFunc(writeCiscoACL), chunks("!", ".cfg")},
+		{formatNXOSACL, FamilyWriterFunc(writeNXOSACL), chunks("!", ".cfg")},
+		{formatJunosPrefixList, FamilyWriterFunc(writeJunosPrefixList), chunks("#", ".set")},
+		{formatJunosConfig, DocumentWriterFunc(writeJunosConfig), nil},
+		{formatJunosFilter, FamilyWriterFunc(writeJunosFilter), nil},
+		{formatBIRDPrefixSet, FamilyWriterFunc(writeBIRDPrefixSet), chunks("#", ".conf")},
+		{formatBIRDStatic, FamilyWriterFunc(writeBIRDStatic), chunks("#", ".conf")},
+		{formatFRRPrefixList, FamilyWriterFunc(writeFRRPrefixList), chunks("!", ".conf")},
+		{formatFRRStatic, FamilyWriterFunc(writeFRRStatic), chunks("!", ".conf")},
+		{formatExaBGP, FamilyWriterFunc(writeExaBGP), nil},
+		{formatGoBGP, FamilyWriterFunc(writeGoBGP), chunks("#", ".sh")},
+		{formatMikroTik, FamilyWriterFunc(writeMikroTik), chunks("#", ".rsc")},
+		{formatPFTable, DocumentWriterFunc(writePFTable), nil},
+		{formatPFSenseAlias, DocumentWriterFunc(writePFSenseAlias), nil},
+		{formatAWSSecurityGroup, DocumentWriterFunc(writeAWSSecurityGroup), nil},
+		{formatAWSPrefixList, DocumentWriterFunc(writeAWSPrefixList), nil},
+		{formatGCPFirewall, DocumentWriterFunc(writeGCPFirewall), nil},
+		{formatAzureNSG, DocumentWriterFunc(writeAzureNSG), nil},
+		{formatNetworkPolicy, DocumentWriterFunc(writeNetworkPolicy), nil},
+		{formatGlobalNetworkSet, DocumentWriterFunc(writeGlobalNetworkSet), nil},
+		{formatNginx, DocumentWriterFunc(writeNginx), nil},
+		{formatNginxGeo, DocumentWriterFunc(writeNginxGeo), nil},
+		{formatApache, DocumentWriterFunc(writeApache), nil},
+		{formatHAProxyACL, FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, _ Options) error { return writeCIDRs(w, cidrs) }), chunks("#", ".acl")},
+		{formatHAProxyMap, FamilyWriterFunc(writeHAProxyMap), chunks("#", ".map")},
+		{formatSquidACL, FamilyWriterFunc(writeSquidACL), chunks("#", ".conf")},
+		{formatZeekIntel, DocumentWriterFunc(writeZeekIntel), nil},
+		{formatSuricataIPRep, FamilyWriterFunc(writeSuricataIPRep), chunks("#", ".list")},
+		{formatSTIX, DocumentWriterFunc(writeSTIX), nil},
+		{formatRBLDNSD, FamilyWriterFunc(writeRBLDNSD), nil},
+		{formatRPZ, FamilyWriterFunc(writeRPZ), chunks(";", ".zone")},
+		{formatPostfixCIDR, FamilyWriterFunc(writePostfixCIDR), chunks("#", ".cidr")},
+		{formatRspamdMap, FamilyWriterFunc(writeRspamdMap), chunks("#", ".map")},
+		{formatEximIPLsearch, FamilyWriterFunc(writeEximIPLsearch), chunks("#", ".txt")},
+		{formatPostgresCopy, DocumentWriterFunc(writePostgresCopy), nil},
+		{formatPostgresInsert, DocumentWriterFunc(writePostgresInsert), nil},
+		{formatSQLRange, FamilyWriterFunc(writeSQLRange), chunks("--", ".sql")},
+		{formatGoSource, DocumentWriterFunc(writeGoSource), nil},
+		{formatGoRanges, DocumentWriterFunc(writeGoRanges), nil},
+		{formatCHeader, DocumentWriterFunc(writeCHeader), nil},
+		{formatIPBatch, FamilyWriterFunc(writeIPBatch), chunks("#", ".batch")},
+		{formatBPFToolLPM, FamilyWriterFunc(writeBPFToolLPM), chunks("#", ".batch")},
+		{formatPowerShell, DocumentWriterFunc(writePowerShell), nil},
+		{formatTemplate, DocumentWriterFunc(writeTemplate), nil},
+	} {
+		r.register(f.name, outputFormat{writer: f.writer, chunks: f.chunks, annotated: annotatedFormats[f.name]})
+	}
+	return r
+}
+
+// writeOutput writes the processed IPv4 and IPv6 networks to w,
+// splitting them into chunks when requested.
+func writeOutput(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if opts.ChunkSize > 0 {
+		return writeChunks(w, ipv4, ipv6, opts)
+	}
+	return writeFormatted(w, ipv4, ipv6, opts)
+}
+
+// writeFormatted writes both address families in the selected output format.
+func writeFormatted(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	format, err := outputFormatFor(opts.Format)
+	if err != nil {
+		return err
+	}
+	return format.writer.WriteNetworks(w, ipv4, ipv6, opts)
+}
+
+// writeCIDRs writes one CIDR per line.
+func writeCIDRs(w io.Writer, cidrs []*CIDR) error {
+	for _, c := range cidrs {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRanges writes one inclusive start-end range per line,
+// merging adjacent networks into a single range.
+func writeRanges(w io.Writer, cidrs []*CIDR) error {
+	for _, r := range mergeRanges(cidrs) {
+		if _, err := fmt.Fprintln(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMasks writes "address mask" pairs using the given mask, the notation
+// parseNetmask accepts. IPv6 has no dotted mask convention, so IPv6 networks
+// fall back to CIDR notation as used by IPv6 ACLs.
+func writeMasks(w io.Writer, cidrs []*CIDR, mask func(*CIDR) net.IP) error {
+	for _, c := range cidrs {
+		var err error
+		if c.bits == 32 {
+			_, err = fmt.Fprintf(w, "%s %s\n", c.ip, mask(c))
+		} else {
+			_, err = fmt.Fprintln(w, c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ipRange is an inclusive range of addresses within one address family.
+type ipRange struct {
+	first *big.Int
+	last  *big.Int
+	bits  int
+}
+
+// String formats the range as start-end, the notation parseRange accepts.
+func (r ipRange) String() string {
+	return fmt.Sprintf("%s-%s", bigIntToIP(r.first, r.bits), bigIntToIP(r.last, r.bits))
+}
+
+// mergeRanges converts a sorted, aggregated list of networks into the
+// maximal inclusive ranges they cover.
+func mergeRanges(cidrs []*CIDR) []ipRange {
+	var ranges []ipRange
+	for i, c := range cidrs {
+		first, last := c.bounds()
+		if i > 0 && cidrs[i-1].adjacentTo(c) {
+			ranges[len(ranges)-1].last = last
+			continue
+		}
+		ranges = append(ranges, ipRange{first: first, last: last, bits: c.bits})
+	}
+	return ranges
+}
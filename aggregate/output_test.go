@@ -0,0 +1,254 @@
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// errWriteFailed is returned by every write to a failingWriter.
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter is an io.Writer whose writes always fail, for checking that
+// writers stop at and return output errors.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWriteFailed }
+
+func TestMergeRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{
+			name:  "Single network",
+			input: []string{"192.0.2.0/24"},
+			want:  []string{"192.0.2.0-192.0.2.255"},
+		},
+		{
+			name:  "Adjacent networks merge",
+			input: []string{"192.0.2.0/24", "192.0.3.0/25"},
+			want:  []string{"192.0.2.0-192.0.3.127"},
+		},
+		{
+			name:  "Gap keeps ranges apart",
+			input: []string{"192.0.2.0/24", "192.0.4.0/24"},
+			want:  []string{"192.0.2.0-192.0.2.255", "192.0.4.0-192.0.4.255"},
+		},
+		{
+			name:  "Single address",
+			input: []string{"10.0.0.1/32"},
+			want:  []string{"10.0.0.1-10.0.0.1"},
+		},
+		{
+			name:  "Top of address space",
+			input: []string{"255.255.255.254/31"},
+			want:  []string{"255.255.255.254-255.255.255.255"},
+		},
+		{
+			name:  "IPv6 adjacent networks merge",
+			input: []string{"2001:db8::/64", "2001:db8:0:1::/65"},
+			want:  []string{"2001:db8::-2001:db8:0:1:7fff:ffff:ffff:ffff"},
+		},
+		{
+			name:  "Empty",
+			input: []string{},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cidrs []*CIDR
+			for _, s := range tt.input {
+				c, _ := parseCIDR(s)
+				cidrs = append(cidrs, c)
+			}
+
+			got := mergeRanges(cidrs)
+
+			if len(got) != len(tt.want) {
+				t.Errorf("mergeRanges() returned %v, want %v", got, tt.want)
+				return
+			}
+
+			for i, r := range got {
+				if r.String() != tt.want[i] {
+					t.Errorf("mergeRanges()[%d] = %q, want %q", i, r.String(), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		want  string
+	}{
+		{name: "Empty family", cidrs: nil, want: ""},
+		{name: "Whole address space", cidrs: []string{"0.0.0.0/0"}, want: "0.0.0.0-255.255.255.255\n"},
+		{name: "IPv6 hosts merge", cidrs: []string{"2001:db8::/128", "2001:db8::1/128"}, want: "2001:db8::-2001:db8::1\n"},
+		{name: "IPv6 gap", cidrs: []string{"2001:db8::/128", "2001:db8::2/128"}, want: "2001:db8::-2001:db8::\n2001:db8::2-2001:db8::2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeRanges(&output, mustParseCIDRs(t, tt.cidrs...)); err != nil {
+				t.Fatalf("writeRanges() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeRanges() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	if err := writeRanges(failingWriter{}, mustParseCIDRs(t, "192.0.2.0/24")); !errors.Is(err, errWriteFailed) {
+		t.Errorf("writeRanges() error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestRunWithRangeFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+	}{
+		{
+			name:       "Non-aligned range round trips",
+			input:      "192.0.2.0-192.0.3.127\n",
+			wantOutput: "192.0.2.0-192.0.3.127\n",
+		},
+		{
+			name:       "Separate families",
+			input:      "10.0.0.0/24\n10.0.1.0/24\n2001:db8::/127\n",
+			wantOutput: "10.0.0.0-10.0.1.255\n2001:db8::-2001:db8::1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, Options{Format: formatRange}); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWriteMasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidrs    []string
+		wildcard bool
+		want     string
+	}{
+		{name: "Empty family", cidrs: nil, want: ""},
+		{name: "Default route netmask", cidrs: []string{"0.0.0.0/0"}, want: "0.0.0.0 0.0.0.0\n"},
+		{name: "Default route wildcard", cidrs: []string{"0.0.0.0/0"}, wildcard: true, want: "0.0.0.0 255.255.255.255\n"},
+		{name: "Non-octet boundary", cidrs: []string{"172.16.0.0/12"}, want: "172.16.0.0 255.240.0.0\n"},
+		{name: "Non-octet boundary wildcard", cidrs: []string{"172.16.0.0/12"}, wildcard: true, want: "172.16.0.0 0.15.255.255\n"},
+		{name: "IPv6 keeps prefix notation", cidrs: []string{"::/0", "2001:db8::1/128"}, wildcard: true, want: "::/0\n2001:db8::1/128\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask := (*CIDR).Netmask
+			if tt.wildcard {
+				mask = (*CIDR).Wildcard
+			}
+			var output bytes.Buffer
+			if err := writeMasks(&output, mustParseCIDRs(t, tt.cidrs...), mask); err != nil {
+				t.Fatalf("writeMasks() unexpected error: %v", err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("writeMasks() = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+
+	for _, cidr := range []string{"192.0.2.0/24", "2001:db8::/32"} {
+		if err := writeMasks(failingWriter{}, mustParseCIDRs(t, cidr), (*CIDR).Netmask); !errors.Is(err, errWriteFailed) {
+			t.Errorf("writeMasks(%s) error = %v, want %v", cidr, err, errWriteFailed)
+		}
+	}
+}
+
+func TestRunWithMaskFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		format     string
+		wantOutput string
+	}{
+		{
+			name:       "Netmask",
+			input:      "192.168.1.0/24\n10.0.0.1\n",
+			format:     formatNetmask,
+			wantOutput: "10.0.0.1 255.255.255.255\n192.168.1.0 255.255.255.0\n",
+		},
+		{
+			name:       "Wildcard",
+			input:      "192.168.1.0/24\n10.0.0.1\n",
+			format:     formatWildcard,
+			wantOutput: "10.0.0.1 0.0.0.0\n192.168.1.0 0.0.0.255\n",
+		},
+		{
+			name:       "Netmask IPv6 falls back to CIDR",
+			input:      "192.168.1.0/24\n2001:db8::/32\n",
+			format:     formatNetmask,
+			wantOutput: "192.168.1.0 255.255.255.0\n2001:db8::/32\n",
+		},
+		{
+			name:       "Wildcard IPv6 falls back to CIDR",
+			input:      "2001:db8::/32\n",
+			format:     formatWildcard,
+			wantOutput: "2001:db8::/32\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.NewReader(tt.input)
+			var output, errOutput bytes.Buffer
+
+			if err := Run(input, &output, &errOutput, Options{Format: tt.format}); err != nil {
+				t.Errorf("Run() unexpected error: %v", err)
+				return
+			}
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("Run() output = %q, want %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+// TestNetmaskFormatRoundTrip tests that netmask output is accepted as input
+func TestNetmaskFormatRoundTrip(t *testing.T) {
+	var first, second, errOutput bytes.Buffer
+
+	input := "192.168.0.0/23\n10.0.0.0/8\n172.16.5.4/30\n"
+	if err := Run(strings.NewReader(input), &first, &errOutput, Options{Format: formatNetmask}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if err := run(strings.NewReader(first.String()), &second, &errOutput); err != nil {
+		t.Fatalf("run() unexpected error: %v", err)
+	}
+
+	want := "10.0.0.0/8\n172.16.5.4/30\n192.168.0.0/23\n"
+	if second.String() != want {
+		t.Errorf("round trip output = %q, want %q", second.String(), want)
+	}
+}
@@ -0,0 +1,200 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// InputParser parses one input line, with any trailing comment removed,
+// into the networks it describes.
+type InputParser interface {
+	// Detect reports whether the line is written in the parser's notation.
+	// It is consulted when no -input-format is given.
+	Detect(line string) bool
+	Parse(line string) ([]*CIDR, error)
+}
+
+// InputReader reads the networks of a whole input document, for formats
+// such as CSV whose records are not simply one per line. Document formats
+// are never detected; they are chosen with -input-format.
+type InputReader interface {
+	ReadNetworks(r io.Reader, errOutput io.Writer, opts Options) ([]*CIDR, error)
+}
+
+// OutputWriter writes the sorted, aggregated networks of both address
+// families in one output format.
+type OutputWriter interface {
+	WriteNetworks(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error
+}
+
+// DocumentWriterFunc adapts a function writing both families in one pass,
+// such as a format wrapping all entries in a single document, to an
+// OutputWriter.
+type DocumentWriterFunc func(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error
+
+// WriteNetworks calls f(w, ipv4, ipv6, opts).
+func (f DocumentWriterFunc) WriteNetworks(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	return f(w, ipv4, ipv6, opts)
+}
+
+// FamilyWriterFunc adapts a function writing the networks of one family to
+// an OutputWriter that writes IPv4 then IPv6.
+type FamilyWriterFunc func(w io.Writer, cidrs []*CIDR, opts Options) error
+
+// WriteNetworks calls f for IPv4 and then for IPv6.
+func (f FamilyWriterFunc) WriteNetworks(w io.Writer, ipv4, ipv6 []*CIDR, opts Options) error {
+	if err := f(w, ipv4, opts); err != nil {
+		return err
+	}
+	return f(w, ipv6, opts)
+}
+
+// lineParser is an InputParser built from a detection and a parse function.
+type lineParser struct {
+	detect func(line string) bool
+	parse  func(line string) ([]*CIDR, error)
+}
+
+// Detect calls p.detect(line).
+func (p lineParser) Detect(line string) bool { return p.detect(line) }
+
+// Parse calls p.parse(line).
+func (p lineParser) Parse(line string) ([]*CIDR, error) { return p.parse(line) }
+
+// RegisterInputFormat makes an input parser available under name, for
+// -input-format and for detection. Custom formats are registered from an
+// init function of a program importing this package; they are detected
+// after the built-in notations, which are registered during variable
+// initialisation, but before the plain CIDR fallback. Registering a name
+// twice panics.
+func RegisterInputFormat(name string, p InputParser) {
+	if _, dup := readers.entries[name]; dup {
+		panic(fmt.Sprintf("input format %q registered twice", name))
+	}
+	inputs.register(name, p)
+}
+
+// RegisterInputReader makes a document input reader available under name,
+// for -input-format. Registering a name twice, or a name already used by a
+// line input format, panics.
+func RegisterInputReader(name string, r InputReader) {
+	if _, dup := inputs.entries[name]; dup {
+		panic(fmt.Sprintf("input format %q registered twice", name))
+	}
+	readers.register(name, r)
+}
+
+// RegisterOutputFormat makes an output writer available under name, for
+// -format. Formats registered this way cannot be combined with -chunk-size;
+// see RegisterChunkedOutputFormat. Custom formats keep the annotations of
+// input lines for EntryComment. Registering a name twice panics.
+func RegisterOutputFormat(name string, w OutputWriter) {
+	outputs.register(name, outputFormat{writer: w, annotated: true})
+}
+
+// ChunkStyle describes how a custom output format is split with -chunk-size.
+// Comment starts the line labelling each section, in the format's own
+// comment syntax, and Ext is the extension of numbered chunk files, such as
+// ".txt".
+type ChunkStyle struct {
+	Comment string
+	Ext     string
+}
+
+// RegisterChunkedOutputFormat registers an output writer like
+// RegisterOutputFormat, for a format that can also be split with
+// -chunk-size. When chunked, the writer is called once per chunk with the
+// networks of one family, and Options.Chunk tells it which chunk it is
+// writing. A style without a comment marker, or registering a name twice,
+// panics.
+func RegisterChunkedOutputFormat(name string, w OutputWriter, style ChunkStyle) {
+	if style.Comment == "" {
+		panic(fmt.Sprintf("output format %q registered without a chunk comment marker", name))
+	}
+	outputs.register(name, outputFormat{writer: w, chunks: chunks(style.Comment, style.Ext), annotated: true})
+}
+
+// registry holds the formats of one kind by name, in registration order.
+type registry[T any] struct {
+	kind    string
+	entries map[string]T
+	names   []string
+}
+
+// newRegistry returns an empty registry for formats of the given kind.
+func newRegistry[T any](kind string) *registry[T] {
+	return &registry[T]{kind: kind, entries: map[string]T{}}
+}
+
+// register adds v under name, panicking if the name is already taken.
+func (r *registry[T]) register(name string, v T) {
+	if _, dup := r.entries[name]; dup {
+		panic(fmt.Sprintf("%s format %q registered twice", r.kind, name))
+	}
+	r.entries[name] = v
+	r.names = append(r.names, name)
+}
+
+// lookup returns the format registered under name.
+func (r *registry[T]) lookup(name string) (T, error) {
+	v, ok := r.entries[name]
+	if !ok {
+		return v, fmt.Errorf("unknown %s format %q (valid: %s)", r.kind, name, strings.Join(r.names, ", "))
+	}
+	return v, nil
+}
+
+// validate checks that name is empty or a registered format.
+func (r *registry[T]) validate(name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := r.lookup(name)
+	return err
+}
+
+// inputParserFor returns the parser for line: the named one, or else the
+// first registered parser detecting the line, falling back to plain CIDR
+// notation.
+func inputParserFor(name, line string) (InputParser, error) {
+	if name != "" {
+		return inputs.lookup(name)
+	}
+	for _, format := range inputs.names {
+		if format != inputCIDR && inputs.entries[format].Detect(line) {
+			return inputs.entries[format], nil
+		}
+	}
+	return inputs.lookup(inputCIDR)
+}
+
+// inputFormatNames lists the line and then the document input formats.
+func inputFormatNames() []string {
+	return append(slices.Clone(inputs.names), readers.names...)
+}
+
+// validateInputFormat checks that name is empty or a registered line or
+// document input format.
+func validateInputFormat(name string) error {
+	if _, ok := readers.entries[name]; ok || inputs.validate(name) == nil {
+		return nil
+	}
+	return fmt.Errorf("unknown input format %q (valid: %s)", name, strings.Join(inputFormatNames(), ", "))
+}
+
+// isDocumentInput reports whether name is a document input format.
+func isDocumentInput(name string) bool {
+	_, ok := readers.entries[name]
+	return ok
+}
+
+// outputFormatFor returns the named output format, or plain CIDR notation
+// when name is empty.
+func outputFormatFor(name string) (outputFormat, error) {
+	if name == "" {
+		name = formatCIDR
+	}
+	return outputs.lookup(name)
+}
@@ -0,0 +1,198 @@
+package aggregate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withRegistries gives a test fresh copies of the built-in registries so it
+// can register formats without affecting other tests.
+func withRegistries(t *testing.T) {
+	t.Helper()
+	savedInputs, savedReaders, savedOutputs := inputs, readers, outputs
+	inputs, readers, outputs = builtinInputs(), builtinReaders(), builtinOutputs()
+	t.Cleanup(func() { inputs, readers, outputs = savedInputs, savedReaders, savedOutputs })
+}
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry[int]("test")
+	r.register("one", 1)
+	r.register("two", 2)
+
+	if got, err := r.lookup("two"); err != nil || got != 2 {
+		t.Errorf("lookup(\"two\") = %d, %v, want 2, nil", got, err)
+	}
+	if _, err := r.lookup("three"); err == nil || !strings.Contains(err.Error(), "valid: one, two") {
+		t.Errorf("lookup(\"three\") error = %v, want unknown format listing one, two", err)
+	}
+	if err := r.validate(""); err != nil {
+		t.Errorf("validate(\"\") = %v, want nil", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("register() of a duplicate name did not panic")
+		}
+	}()
+	r.register("one", 3)
+}
+
+func TestParseInputAs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Detected range", input: "192.168.1.0-3", want: []string{"192.168.1.0/30"}},
+		{name: "Forced range", input: "192.168.1.0-3", format: inputRange, want: []string{"192.168.1.0/30"}},
+		{name: "Forced CIDR rejects range", input: "192.168.1.0-3", format: inputCIDR, wantErr: true},
+		{name: "Forced netmask", input: "10.0.0.0 255.0.0.0 ; comment", format: inputNetmask, want: []string{"10.0.0.0/8"}},
+		{name: "Forced netmask without mask", input: "10.0.0.0", format: inputNetmask, wantErr: true},
+		{name: "Forced wildcard", input: "10.*.*.*", format: inputWildcard, want: []string{"10.0.0.0/8"}},
+		{name: "Comment with forced format", input: "# 10.0.0.0", format: inputNetmask, want: nil},
+		{name: "Unknown format", input: "10.0.0.0/8", format: "bogus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInputAs(tt.input, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseInputAs(%q, %q) expected error, got %v", tt.input, tt.format, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseInputAs(%q, %q) unexpected error: %v", tt.input, tt.format, err)
+			}
+
+			var gotStrs []string
+			for _, c := range got {
+				gotStrs = append(gotStrs, c.String())
+			}
+			if strings.Join(gotStrs, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("parseInputAs(%q, %q) = %v, want %v", tt.input, tt.format, gotStrs, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterCustomFormats(t *testing.T) {
+	withRegistries(t)
+
+	// An input notation of "host:port" lines and an output of bare
+	// addresses with their prefix length in brackets
+	RegisterInputFormat("hostport", lineParser{
+		detect: func(line string) bool { return strings.Count(line, ":") == 1 },
+		parse: func(line string) ([]*CIDR, error) {
+			host, _, _ := strings.Cut(line, ":")
+			c, err := ParseCIDR(host)
+			if err != nil {
+				return nil, err
+			}
+			return []*CIDR{c}, nil
+		},
+	})
+	RegisterOutputFormat("bracketed", FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, _ Options) error {
+		for _, c := range cidrs {
+			if _, err := fmt.Fprintf(w, "%s [%d]\n", c.Addr(), c.Prefix()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	input := strings.NewReader("192.168.1.0:443\n192.168.1.1:80\n2001:db8::/32\n")
+	var output, errOutput bytes.Buffer
+
+	if err := Run(input, &output, &errOutput, Options{Format: "bracketed"}); err != nil {
+		t.Fatalf("Run() unexpected error: %v (%s)", err, errOutput.String())
+	}
+
+	want := "192.168.1.0 [31]\n2001:db8:: [32]\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+
+	RegisterInputReader("ssv", csvReader{comma: ';'})
+	output.Reset()
+	input = strings.NewReader("web;10.0.0.0/8\n")
+	if err := Run(input, &output, &errOutput, Options{InputFormat: "ssv", Column: "2"}); err != nil {
+		t.Fatalf("Run() unexpected error: %v (%s)", err, errOutput.String())
+	}
+	if output.String() != "10.0.0.0/8\n" {
+		t.Errorf("Run() output = %q, want %q", output.String(), "10.0.0.0/8\n")
+	}
+
+	if _, _, err := ParseFlags([]string{"-format", "bracketed", "-input-format", "ssv"}, io.Discard); err != nil {
+		t.Errorf("ParseFlags() rejected a registered reader: %v", err)
+	}
+	if _, _, err := ParseFlags([]string{"-format", "bracketed", "-input-format", "hostport"}, io.Discard); err != nil {
+		t.Errorf("ParseFlags() rejected registered formats: %v", err)
+	}
+}
+
+func TestRegisterChunkedOutputFormat(t *testing.T) {
+	withRegistries(t)
+
+	RegisterChunkedOutputFormat("numbered-list", FamilyWriterFunc(func(w io.Writer, cidrs []*CIDR, opts Options) error {
+		_, start := opts.Chunk()
+		for i, c := range cidrs {
+			if _, err := fmt.Fprintf(w, "%d %s\n", start+i+1, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), ChunkStyle{Comment: "//", Ext: ".lst"})
+
+	opts, _, err := ParseFlags([]string{"-format", "numbered-list", "-chunk-size", "2"}, io.Discard)
+	if err != nil {
+		t.Fatalf("ParseFlags() rejected a chunked custom format: %v", err)
+	}
+	input := strings.NewReader("10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n")
+	var output, errOutput bytes.Buffer
+
+	if err := Run(input, &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v (%s)", err, errOutput.String())
+	}
+
+	want := "// ipv4 chunk 1/2 (2 entries)\n1 10.0.0.0/24\n2 10.0.2.0/24\n" +
+		"// ipv4 chunk 2/2 (1 entries)\n3 10.0.4.0/24\n"
+	if output.String() != want {
+		t.Errorf("Run() output = %q, want %q", output.String(), want)
+	}
+
+	prefix := filepath.Join(t.TempDir(), "list")
+	opts.ChunkPrefix = prefix
+	if err := Run(strings.NewReader("10.0.0.0/24\n"), &output, &errOutput, opts); err != nil {
+		t.Fatalf("Run() unexpected error: %v (%s)", err, errOutput.String())
+	}
+	if _, err := os.Stat(prefix + "-ipv4-001.lst"); err != nil {
+		t.Errorf("chunk file with the format's extension not written: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterChunkedOutputFormat() without a comment marker did not panic")
+		}
+	}()
+	RegisterChunkedOutputFormat("unlabelled", DocumentWriterFunc(writePFTable), ChunkStyle{Ext: ".txt"})
+}
+
+func TestRegisterInputReaderNameClash(t *testing.T) {
+	withRegistries(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterInputReader() of a line input format name did not panic")
+		}
+	}()
+	RegisterInputReader(inputRange, csvReader{comma: ';'})
+}